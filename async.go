@@ -0,0 +1,138 @@
+package lunk
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// An AsyncEventLogger logs events asynchronously. Entries are created on the
+// caller's goroutine and added to a bounded queue, from which a background
+// goroutine passes them to the underlying EventLogger. If the underlying
+// EventLogger isn't one of this package's, it's passed the original events
+// instead, and creates their entries itself.
+//
+// When the queue is full, entries are dropped unless the AsyncEventLogger has
+// been configured to block. Entries which the underlying EventLogger fails to
+// log are also counted as dropped. To handle those failures instead, wrap the
+// underlying EventLogger with an ErrorHandlingEventLogger.
+type AsyncEventLogger struct {
+	dropped uint64 // first, for 64-bit alignment
+	block   int32
+	l       EventLogger
+	queue   chan asyncItem
+	done    chan struct{}
+	closed  bool
+	m       *sync.RWMutex
+}
+
+type asyncItem struct {
+	entry   Entry
+	event   Event
+	flushed chan struct{}
+}
+
+// NewAsyncEventLogger returns a new AsyncEventLogger, passing events through to
+// the given EventLogger, with a queue which holds up to size entries.
+func NewAsyncEventLogger(l EventLogger, size int) *AsyncEventLogger {
+	al := &AsyncEventLogger{
+		l:     l,
+		queue: make(chan asyncItem, size),
+		done:  make(chan struct{}),
+		m:     new(sync.RWMutex),
+	}
+	go al.run()
+	return al
+}
+
+// SetBlocking sets whether or not Log blocks when the queue is full, rather than
+// dropping the entry.
+func (l *AsyncEventLogger) SetBlocking(block bool) {
+	var v int32
+	if block {
+		v = 1
+	}
+	atomic.StoreInt32(&l.block, v)
+}
+
+// Log adds the event to the queue.
+func (l *AsyncEventLogger) Log(id EventID, e Event) {
+	l.logEntry(NewEntry(id, e), e)
+}
+
+func (l *AsyncEventLogger) logEntry(entry Entry, e Event) error {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	if l.closed {
+		atomic.AddUint64(&l.dropped, 1)
+		return nil
+	}
+
+	item := asyncItem{entry: entry, event: e}
+	if atomic.LoadInt32(&l.block) == 1 {
+		l.queue <- item
+		return nil
+	}
+
+	select {
+	case l.queue <- item:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+	return nil
+}
+
+// Dropped returns the number of entries which have been dropped.
+func (l *AsyncEventLogger) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Flush blocks until all entries queued before the call have been passed to
+// the underlying EventLogger, then flushes the underlying EventLogger, if it's a
+// Flusher.
+func (l *AsyncEventLogger) Flush() error {
+	l.m.RLock()
+	if l.closed {
+		l.m.RUnlock()
+		return nil
+	}
+
+	flushed := make(chan struct{})
+	l.queue <- asyncItem{flushed: flushed}
+	l.m.RUnlock()
+
+	<-flushed
+	return flush(l.l)
+}
+
+// Close stops accepting new entries, blocks until all queued entries have been
+// passed to the underlying EventLogger, then closes the underlying EventLogger,
+// if it's a Closer, or flushes it, if it's a Flusher. Events logged after Close
+// are dropped.
+func (l *AsyncEventLogger) Close() error {
+	l.m.Lock()
+	if l.closed {
+		l.m.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.queue)
+	l.m.Unlock()
+
+	<-l.done
+	return closeOrFlush(l.l)
+}
+
+func (l *AsyncEventLogger) run() {
+	defer close(l.done)
+
+	for item := range l.queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		if err := logEntry(l.l, item.entry, item.event); err != nil {
+			atomic.AddUint64(&l.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package lunk
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+var _ EventLogger = &AsyncEventLogger{}
+
+func TestAsyncEventLogger(t *testing.T) {
+	l := fakeLogger{}
+	al := NewAsyncEventLogger(&l, 100)
+
+	for i := 0; i < 50; i++ {
+		al.Log(EventID{ID: ID(i)}, mockEvent{Example: "whee"})
+	}
+	al.Flush()
+
+	if len(l.events) != 50 {
+		t.Fatalf("Unexpected number of events: %d", len(l.events))
+	}
+
+	for i, e := range l.events {
+		if e.id.ID != ID(i) {
+			t.Errorf("Event %d had ID %v", i, e.id)
+		}
+
+		entry := NewEntry(e.id, e.e)
+		if entry.Schema != "example" || entry.Properties["example"] != "whee" {
+			t.Errorf("Unexpected entry: %+v", entry)
+		}
+	}
+
+	if al.Dropped() != 0 {
+		t.Errorf("Unexpectedly dropped %d events", al.Dropped())
+	}
+}
+
+func TestAsyncEventLoggerPreservesEntries(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	al := NewAsyncEventLogger(NewJSONEventLogger(buf), 10)
+
+	id := NewRootEventID()
+	al.logEntry(NewEntry(id, mockEvent{Example: "whee"}), mockEvent{Example: "whee"})
+	expected := NewEntry(id, mockEvent{Example: "whee"})
+	expected.Time = expected.Time.Add(-1000)
+	al.logEntry(expected, mockEvent{Example: "whee"})
+	al.Close()
+
+	dec := json.NewDecoder(buf)
+	var actual Entry
+	for dec.More() {
+		if err := dec.Decode(&actual); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !actual.Time.Equal(expected.Time) {
+		t.Errorf("Was %v, but expected %v", actual.Time, expected.Time)
+	}
+}
+
+func TestAsyncEventLoggerCustomLogger(t *testing.T) {
+	fake := &fakeLogger{}
+	al := NewAsyncEventLogger(fake, 10)
+
+	id := NewRootEventID()
+	al.Log(id, mockEvent{Example: "whee"})
+	al.Close()
+
+	if len(fake.events) != 1 || fake.events[0].id != id || fake.events[0].e != (mockEvent{Example: "whee"}) {
+		t.Errorf("Unexpected events: %+v", fake.events)
+	}
+}
+
+func TestAsyncEventLoggerDropping(t *testing.T) {
+	l := blockingLogger{unblock: make(chan struct{})}
+	al := NewAsyncEventLogger(l, 5)
+
+	for i := 0; i < 20; i++ {
+		al.Log(EventID{ID: ID(i)}, mockEvent{})
+	}
+
+	// at most the queue plus the entry being written are retained
+	if al.Dropped() < 14 {
+		t.Errorf("Unexpectedly few dropped events: %d", al.Dropped())
+	}
+
+	close(l.unblock)
+	al.Close()
+}
+
+func TestAsyncEventLoggerBlocking(t *testing.T) {
+	l := fakeLogger{}
+	al := NewAsyncEventLogger(&l, 1)
+	al.SetBlocking(true)
+
+	for i := 0; i < 100; i++ {
+		al.Log(EventID{ID: ID(i)}, mockEvent{})
+	}
+	al.Close()
+
+	if len(l.events) != 100 {
+		t.Errorf("Unexpected number of events: %d", len(l.events))
+	}
+
+	if al.Dropped() != 0 {
+		t.Errorf("Unexpectedly dropped %d events", al.Dropped())
+	}
+}
+
+func TestAsyncEventLoggerClose(t *testing.T) {
+	l := fakeLogger{}
+	al := NewAsyncEventLogger(&l, 10)
+	al.Log(NewRootEventID(), mockEvent{})
+	al.Close()
+	al.Close()
+	al.Flush()
+	al.Log(NewRootEventID(), mockEvent{})
+
+	if len(l.events) != 1 {
+		t.Errorf("Unexpected number of events: %d", len(l.events))
+	}
+
+	if al.Dropped() != 1 {
+		t.Errorf("Unexpected number of dropped events: %d", al.Dropped())
+	}
+}
+
+func TestAsyncEventLoggerPanics(t *testing.T) {
+	al := NewAsyncEventLogger(panickingLogger{}, 10)
+	al.Log(NewRootEventID(), mockEvent{})
+	al.Close()
+
+	if al.Dropped() != 1 {
+		t.Errorf("Unexpected number of dropped events: %d", al.Dropped())
+	}
+}
+
+func TestAsyncEventLoggerCloseCascades(t *testing.T) {
+	w := &closingWriter{}
+	al := NewAsyncEventLogger(NewJSONEventLogger(w), 10)
+	al.Log(NewRootEventID(), mockEvent{})
+
+	if err := al.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.writes != 1 {
+		t.Errorf("Unexpected number of writes: %d", w.writes)
+	}
+
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.closed {
+		t.Errorf("Writer wasn't closed")
+	}
+}
+
+type blockingLogger struct {
+	unblock chan struct{}
+}
+
+func (l blockingLogger) Log(id EventID, e Event) {
+	<-l.unblock
+}
+
+type panickingLogger struct{}
+
+func (panickingLogger) Log(id EventID, e Event) {
+	panic("woo")
+}
+
+func BenchmarkAsyncEventLogger(b *testing.B) {
+	ev := mockEvent{Example: "whee"}
+	logger := NewAsyncEventLogger(nullEventLogger{}, 1024)
+	id := NewRootEventID()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Log(id, ev)
+	}
+	logger.Close()
+}
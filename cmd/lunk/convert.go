@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codahale/lunk"
+)
+
+// convert reads entries in one format and writes them in another.
+func convert(args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	fs := newFlagSet("convert", "[file]", stderr)
+	from := fs.String("from", "", "the input `format`: text, json, csv, or ncsv")
+	to := fs.String("to", "", "the output `format`: text, json, csv, or ncsv")
+	out := fs.String("o", "", "the output `file` (default stdout)")
+	fromProps := fs.String("from-props", "", "the input properties `file`, for ncsv input")
+	toProps := fs.String("to-props", "", "the output properties `file`, for ncsv output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *from == "" || *to == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	in, name := stdin, "stdin"
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		name = fs.Arg(0)
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var propsIn io.Reader
+	if *fromProps != "" {
+		f, err := os.Open(*fromProps)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		propsIn = f
+	}
+
+	r, err := newEntryReader(*from, in, propsIn)
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer closeFile(f, &err)
+		w = f
+	}
+
+	var propsOut io.Writer
+	if *toProps != "" {
+		f, err := os.Create(*toProps)
+		if err != nil {
+			return err
+		}
+		defer closeFile(f, &err)
+		propsOut = f
+	}
+
+	rec, err := newEntryRecorder(*to, w, propsOut)
+	if err != nil {
+		return err
+	}
+
+	if err := copyEntries(rec, r, name); err != nil {
+		return err
+	}
+	return rec.(lunk.Closer).Close()
+}
+
+// copyEntries records all of the entries read from the given EntryReader. name
+// is the name of the EntryReader's input, which is added to errors.
+func copyEntries(rec lunk.EntryRecorder, r lunk.EntryReader, name string) error {
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if err := rec.Record(e); err != nil {
+			return err
+		}
+	}
+}
+
+// closeFile closes the given file, setting err to the resulting error if it is
+// not already set.
+func closeFile(f *os.File, err *error) {
+	if cerr := f.Close(); *err == nil {
+		*err = cerr
+	}
+}
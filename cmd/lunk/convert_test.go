@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testText = `time="2014-05-20T14:42:38Z" host="example.com" pid="600" deploy="r500" schema="event" id="00000000000000c8" root="0000000000000064" p:k1="v1" p:k2="v2"
+time="2014-05-20T14:42:39Z" host="example.com" pid="600" deploy="r500" schema="event" id="00000000000000c9" root="0000000000000064" parent="00000000000000c8" p:k3="v3"
+`
+	testJSON = `{"root":"0000000000000064","id":"00000000000000c8","schema":"event","time":"2014-05-20T14:42:38Z","host":"example.com","deploy":"r500","pid":600,"properties":{"k1":"v1","k2":"v2"}}
+{"root":"0000000000000064","id":"00000000000000c9","parent":"00000000000000c8","schema":"event","time":"2014-05-20T14:42:39Z","host":"example.com","deploy":"r500","pid":600,"properties":{"k3":"v3"}}
+`
+)
+
+func TestConvert(t *testing.T) {
+	status, stdout, stderr := runLunk(t, testText, "convert", "-from", "text", "-to", "json")
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	if stdout != testJSON {
+		t.Errorf("Was %q, but expected %q", stdout, testJSON)
+	}
+}
+
+func TestConvertFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	if err := os.WriteFile(path("in.json"), []byte(testJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"-from", "json", "-to", "ncsv", "-o", path("events.csv"), "-to-props", path("props.csv"), path("in.json")},
+		{"-from", "ncsv", "-to", "csv", "-o", path("out.csv"), "-from-props", path("props.csv"), path("events.csv")},
+		{"-from", "csv", "-to", "text", "-o", path("out.txt"), path("out.csv")},
+	} {
+		status, _, stderr := runLunk(t, "", append([]string{"convert"}, args...)...)
+		if status != 0 {
+			t.Fatalf("Unexpected status %d: %s", status, stderr)
+		}
+	}
+
+	b, err := os.ReadFile(path("out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != testText {
+		t.Errorf("Was %q, but expected %q", s, testText)
+	}
+}
+
+func TestConvertCSVWithoutProperties(t *testing.T) {
+	in := testJSON + `{"root":"0000000000000064","id":"00000000000000ca","parent":"00000000000000c8","schema":"event","time":"2014-05-20T14:42:40Z","host":"example.com","deploy":"r500","pid":600,"properties":{}}
+`
+
+	status, csv, stderr := runLunk(t, in, "convert", "-from", "json", "-to", "csv")
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	status, stdout, stderr := runLunk(t, csv, "convert", "-from", "csv", "-to", "json")
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	if stdout != in {
+		t.Errorf("Was %q, but expected %q", stdout, in)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for _, test := range []struct {
+		stdin    string
+		args     []string
+		expected string
+	}{
+		{"woo\n", []string{"-from", "text", "-to", "json"}, `lunk convert: stdin: line 1, column 1: missing '='`},
+		{`schema="event"` + "\n", []string{"-from", "text", "-to", "json"}, `lunk convert: stdin: line 1: missing attribute "time"`},
+		{"", []string{"-from", "xml", "-to", "json"}, `lunk convert: unknown format "xml"`},
+		{"", []string{"-from", "text", "-to", "ncsv"}, `lunk convert: the ncsv format requires a properties file`},
+		{"", []string{"-from", "text", "-to", "json", "/nonexistent/woo"}, `lunk convert: open /nonexistent/woo: no such file or directory`},
+	} {
+		status, _, stderr := runLunk(t, test.stdin, append([]string{"convert"}, test.args...)...)
+		if status != 1 {
+			t.Errorf("Was %d, but expected %d", status, 1)
+		}
+
+		if s := strings.TrimSpace(stderr); s != test.expected {
+			t.Errorf("Was %q, but expected %q", s, test.expected)
+		}
+	}
+}
+
+func TestConvertUsage(t *testing.T) {
+	status, _, stderr := runLunk(t, "", "convert", "-from", "text")
+	if status != 2 {
+		t.Errorf("Was %d, but expected %d", status, 2)
+	}
+
+	if !strings.HasPrefix(stderr, "Usage: lunk convert [flags] [file]") {
+		t.Errorf("Unexpected usage: %q", stderr)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codahale/lunk"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatNCSV = "ncsv"
+)
+
+var errNoProps = errors.New("the ncsv format requires a properties file")
+
+// newEntryReader returns an EntryReader for the given format. props is only
+// used by the ncsv format.
+func newEntryReader(format string, r, props io.Reader) (lunk.EntryReader, error) {
+	switch format {
+	case formatText:
+		return lunk.NewTextEntryReader(r), nil
+	case formatJSON:
+		return lunk.NewJSONEntryReader(r), nil
+	case formatCSV:
+		return lunk.NewDenormalizedCSVEntryReader(csv.NewReader(bufio.NewReader(r))), nil
+	case formatNCSV:
+		if props == nil {
+			return nil, errNoProps
+		}
+		return lunk.NewNormalizedCSVEntryReader(
+			csv.NewReader(bufio.NewReader(r)),
+			csv.NewReader(bufio.NewReader(props)),
+		), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// newEntryRecorder returns an EntryRecorder for the given format, having
+// written any header rows. props is only used by the ncsv format. The
+// EntryRecorder must be closed to flush its output.
+func newEntryRecorder(format string, w, props io.Writer) (lunk.EntryRecorder, error) {
+	switch format {
+	case formatText:
+		return lunk.NewLoggingEntryRecorder(lunk.NewTextEventLogger(bufio.NewWriter(w))), nil
+	case formatJSON:
+		return lunk.NewLoggingEntryRecorder(lunk.NewJSONEventLogger(bufio.NewWriter(w))), nil
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(lunk.DenormalizedEventHeaders); err != nil {
+			return nil, err
+		}
+		return lunk.NewDenormalizedCSVEntryRecorder(cw), nil
+	case formatNCSV:
+		if props == nil {
+			return nil, errNoProps
+		}
+
+		ew, pw := csv.NewWriter(w), csv.NewWriter(props)
+		if err := ew.Write(lunk.NormalizedEventHeaders); err != nil {
+			return nil, err
+		}
+		if err := pw.Write(lunk.NormalizedPropertyHeaders); err != nil {
+			return nil, err
+		}
+		return lunk.NewNormalizedCSVEntryRecorder(ew, pw), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// newLogReader returns an EntryReader for a text or JSON log, which may be
+// gzip-compressed. JSON logs are detected by their first non-blank character.
+func newLogReader(r io.Reader) (lunk.EntryReader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(zr)
+	}
+
+	// look at the start of the log for its first non-blank character
+	start, _ := br.Peek(4096)
+	if b := bytes.TrimSpace(start); len(b) > 0 && b[0] == '{' {
+		return lunk.NewJSONEntryReader(br), nil
+	}
+	return lunk.NewTextEntryReader(br), nil
+}
+
+// scanLog calls the given function with each of the entries in the named text
+// or JSON log, or stdin if the name is "-". Unparseable entries are reported
+// and skipped, since logs from many hosts rarely agree on everything.
+func scanLog(command, name string, stdin io.Reader, stderr io.Writer, f func(lunk.Entry) error) error {
+	in := stdin
+	if name == "-" {
+		name = "stdin"
+	} else {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	r, err := newLogReader(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			var pe *lunk.ParseError
+			if errors.As(err, &pe) {
+				fmt.Fprintf(stderr, "lunk %s: %s: %v\n", command, name, err)
+				continue
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if err := f(e); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/codahale/lunk"
+	"github.com/codahale/lunk/query"
+)
+
+// grep writes the entries from text or JSON logs which match a query.
+func grep(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("grep", "<query> [files...]", stderr)
+	to := fs.String("to", formatJSON, "the output `format`: text, json, or csv")
+	count := fs.Bool("c", false, "print only the number of matching entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	q, err := query.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("bad query: %w", err)
+	}
+
+	files := fs.Args()[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var rec lunk.EntryRecorder
+	if !*count {
+		if rec, err = newEntryRecorder(*to, stdout, nil); err != nil {
+			return err
+		}
+	}
+
+	n := 0
+	for _, name := range files {
+		err = scanLog("grep", name, stdin, stderr, func(e lunk.Entry) error {
+			if !q.Match(e) {
+				return nil
+			}
+
+			n++
+			if rec != nil {
+				return rec.Record(e)
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+	}
+
+	if *count {
+		if err == nil {
+			_, err = fmt.Fprintln(stdout, n)
+		}
+		return err
+	}
+
+	// write any matching entries, even if a log couldn't be read
+	if cerr := rec.(lunk.Closer).Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
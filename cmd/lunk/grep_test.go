@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	edge, app := writeTestLogs(t)
+
+	status, stdout, stderr := runLunk(t, "", "grep", "-to", "text", "p:elapsed >= 250", edge, app)
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	expected := `time="2014-05-20T14:42:38Z" host="edge-1" pid="1" deploy="" schema="httprequest" id="00000000000000c8" root="0000000000000064" p:elapsed="1000" p:status="200"
+time="2014-05-20T14:42:37Z" host="app-1" pid="2" deploy="" schema="query" id="00000000000000c9" root="0000000000000064" parent="00000000000000c8" p:elapsed="250"
+`
+	if stdout != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", stdout, expected)
+	}
+}
+
+func TestGrepCount(t *testing.T) {
+	edge, app := writeTestLogs(t)
+
+	status, stdout, stderr := runLunk(t, "", "grep", "-c", "host =~ ^edge", edge, app)
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	if stdout != "2\n" {
+		t.Errorf("Was %q, but expected %q", stdout, "2\n")
+	}
+}
+
+func TestGrepStdin(t *testing.T) {
+	status, stdout, stderr := runLunk(t, testJSON, "grep", "p:k3 = v3")
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	expected := strings.SplitAfter(testJSON, "\n")[1]
+	if stdout != expected {
+		t.Errorf("Was %q, but expected %q", stdout, expected)
+	}
+}
+
+func TestGrepErrors(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"woo"}, `lunk grep: bad query: column 1: unknown field "woo"`},
+		{[]string{"-to", "ncsv", "host = woo"}, `lunk grep: the ncsv format requires a properties file`},
+		{[]string{"host = woo", "/nonexistent/woo"}, `lunk grep: open /nonexistent/woo: no such file or directory`},
+	} {
+		status, _, stderr := runLunk(t, "", append([]string{"grep"}, test.args...)...)
+		if status != 1 {
+			t.Errorf("Was %d, but expected %d", status, 1)
+		}
+
+		if s := strings.TrimSpace(stderr); s != test.expected {
+			t.Errorf("Was %q, but expected %q", s, test.expected)
+		}
+	}
+}
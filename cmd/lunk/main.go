@@ -0,0 +1,102 @@
+// Command lunk reads, converts, and analyzes lunk log files.
+//
+// Usage:
+//
+//	lunk <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	convert  convert entries from one log format to another
+//	tree     print the tree of events with a root ID as a waterfall
+//	grep     print the entries which match a query
+//
+// Run "lunk <command> -h" for a command's flags. The query language used by
+// grep is described by the query package.
+//
+// The supported log formats are:
+//
+//	text  lines of attr="value" text, as written by NewTextEventLogger
+//	json  lines of JSON, as written by NewJSONEventLogger
+//	csv   denormalized CSV, as written by NewDenormalizedCSVEntryRecorder
+//	ncsv  normalized CSV, as written by NewNormalizedCSVEntryRecorder, with
+//	      events and properties in separate files
+//
+// CSV files have header rows. Files are read and written as streams, so files
+// of any size can be processed.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A command is a lunk subcommand.
+type command struct {
+	name, summary string
+	run           func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{"convert", "convert entries from one log format to another", convert},
+	{"tree", "print the tree of events with a root ID as a waterfall", printTree},
+	{"grep", "print the entries which match a query", grep},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command given by the arguments, returning the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+
+		if err := c.run(args[1:], stdin, stdout, stderr); err != nil {
+			if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+				return 2
+			}
+			fmt.Fprintf(stderr, "lunk %s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "lunk: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: lunk <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s  %s\n", c.name, c.summary)
+	}
+}
+
+// errUsage is returned by commands whose arguments are invalid, after they've
+// printed their usage.
+var errUsage = errors.New("usage")
+
+// newFlagSet returns a flag set for the given command, which prints its usage
+// to the given writer.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: lunk %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
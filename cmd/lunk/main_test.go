@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func runLunk(t *testing.T, stdin string, args ...string) (int, string, string) {
+	stdout, stderr := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	status := run(args, strings.NewReader(stdin), stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRunNoCommand(t *testing.T) {
+	status, _, stderr := runLunk(t, "")
+	if status != 2 {
+		t.Errorf("Was %d, but expected %d", status, 2)
+	}
+
+	if !strings.Contains(stderr, "convert") {
+		t.Errorf("Unexpected usage: %q", stderr)
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	status, _, stderr := runLunk(t, "", "woo")
+	if status != 2 {
+		t.Errorf("Was %d, but expected %d", status, 2)
+	}
+
+	if !strings.HasPrefix(stderr, `lunk: unknown command "woo"`) {
+		t.Errorf("Unexpected error: %q", stderr)
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	status, _, stderr := runLunk(t, "", "convert", "-h")
+	if status != 2 {
+		t.Errorf("Was %d, but expected %d", status, 2)
+	}
+
+	if !strings.HasPrefix(stderr, "Usage: lunk convert") {
+		t.Errorf("Unexpected usage: %q", stderr)
+	}
+}
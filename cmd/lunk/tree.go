@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codahale/lunk"
+	"github.com/codahale/lunk/tree"
+)
+
+// printTree collects the entries with a root ID from text or JSON logs and
+// prints their tree as a waterfall. Text logs record times to the second, so it
+// warns when the tree's timings depend on such times.
+func printTree(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("tree", "<root-id> [files...]", stderr)
+	width := fs.Int("width", tree.DefaultWaterfallWidth, "the `width` of the timeline")
+	props := fs.String("props", "", "a comma-separated `list` of properties to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	root, err := lunk.ParseID(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("bad root ID %q", fs.Arg(0))
+	}
+
+	files := fs.Args()[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	b := tree.NewBuilder()
+	var n, imprecise int
+	for _, name := range files {
+		if err := scanLog("tree", name, stdin, stderr, func(e lunk.Entry) error {
+			if e.Root == root {
+				b.Add(e)
+				n++
+				if timedBySeconds(e) {
+					imprecise++
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	t := b.Tree(root)
+	if t == nil {
+		return fmt.Errorf("no entries found for root ID %s", root)
+	}
+
+	if n > 1 && imprecise > 0 {
+		fmt.Fprintf(stderr, "lunk tree: %d of %d entries have whole-second times, so timings may be off by up to a second\n", imprecise, n)
+	}
+
+	wf := tree.Waterfall{Width: *width}
+	if *props != "" {
+		wf.Properties = strings.Split(*props, ",")
+	}
+	return wf.Write(stdout, t)
+}
+
+// timedBySeconds returns whether or not the entry has a whole-second time (e.g.,
+// from a text log) on which its interval depends. Intervals don't depend on the
+// entry's time if it has an "end" property, or both "start" and "elapsed"
+// properties; see tree.Interval.
+func timedBySeconds(e lunk.Entry) bool {
+	if e.Time.Nanosecond() != 0 {
+		return false
+	}
+
+	_, end := e.Properties["end"]
+	_, start := e.Properties["start"]
+	_, elapsed := e.Properties["elapsed"]
+	return !end && !(start && elapsed)
+}
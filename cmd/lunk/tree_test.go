@@ -0,0 +1,105 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestLogs(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	edge := filepath.Join(dir, "edge.log")
+	if err := os.WriteFile(edge, []byte(
+		`time="2014-05-20T14:42:38Z" host="edge-1" pid="1" deploy="" schema="httprequest" id="00000000000000c8" root="0000000000000064" p:elapsed="1000" p:status="200"
+woo
+time="2014-05-20T14:42:38Z" host="edge-1" pid="1" deploy="" schema="httprequest" id="00000000000000ff" root="00000000000000ff" p:elapsed="10"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := filepath.Join(dir, "app.json.gz")
+	f, err := os.Create(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := gzip.NewWriter(f)
+	w.Write([]byte(`
+{"root":"0000000000000064","id":"00000000000000c9","parent":"00000000000000c8","schema":"query","time":"2014-05-20T14:42:37.5Z","host":"app-1","pid":2,"properties":{"elapsed":"250"}}
+`))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return edge, app
+}
+
+func TestTree(t *testing.T) {
+	edge, app := writeTestLogs(t)
+
+	status, stdout, stderr := runLunk(t, "", "tree", "-width", "4", "-props", "status", "0000000000000064", edge, app)
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	expected := `tree 0000000000000064: 2 events over 1000.000ms
+SCHEMA       HOST    START       ELAPSED     TIMELINE
+httprequest  edge-1  +0.000ms    1000.000ms  |####|  status="200"
+  query      app-1   +250.000ms  250.000ms   | #  |
+`
+	if stdout != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", stdout, expected)
+	}
+
+	if !strings.HasPrefix(stderr, "lunk tree: "+edge+": line 2, column 1: missing '='") {
+		t.Errorf("Unexpected warning: %q", stderr)
+	}
+
+	// the edge entry comes from a text log
+	if !strings.HasSuffix(stderr, "lunk tree: 1 of 2 entries have whole-second times, so timings may be off by up to a second\n") {
+		t.Errorf("Unexpected warning: %q", stderr)
+	}
+}
+
+func TestTreeStdin(t *testing.T) {
+	status, stdout, stderr := runLunk(t,
+		`{"root":"0000000000000064","id":"0000000000000064","schema":"event","time":"2014-05-20T14:42:38Z"}`,
+		"tree", "0000000000000064")
+	if status != 0 {
+		t.Fatalf("Unexpected status %d: %s", status, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "tree 0000000000000064: 1 events") {
+		t.Errorf("Unexpected output: %q", stdout)
+	}
+}
+
+func TestTreeErrors(t *testing.T) {
+	edge, _ := writeTestLogs(t)
+
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"woo"}, `lunk tree: bad root ID "woo"`},
+		{[]string{"0000000000000001", edge}, `lunk tree: no entries found for root ID 0000000000000001`},
+		{[]string{"0000000000000001", "/nonexistent/woo"}, `lunk tree: open /nonexistent/woo: no such file or directory`},
+	} {
+		status, _, stderr := runLunk(t, "", append([]string{"tree"}, test.args...)...)
+		if status != 1 {
+			t.Errorf("Was %d, but expected %d", status, 1)
+		}
+
+		lines := strings.Split(strings.TrimSpace(stderr), "\n")
+		if s := lines[len(lines)-1]; s != test.expected {
+			t.Errorf("Was %q, but expected %q", s, test.expected)
+		}
+	}
+}
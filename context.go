@@ -0,0 +1,32 @@
+package lunk
+
+import "context"
+
+type contextKey int
+
+const eventIDKey contextKey = 0
+
+// NewContext returns a copy of the given context which carries the given
+// EventID.
+func NewContext(ctx context.Context, id EventID) context.Context {
+	return context.WithValue(ctx, eventIDKey, id)
+}
+
+// FromContext returns the EventID carried by the given context, if any.
+func FromContext(ctx context.Context) (EventID, bool) {
+	id, ok := ctx.Value(eventIDKey).(EventID)
+	return id, ok
+}
+
+// ChildContext returns a copy of the given context which carries a new EventID,
+// along with the new EventID. If the given context carries an EventID, the new
+// EventID is its child; otherwise, the new EventID is for a root event.
+func ChildContext(ctx context.Context) (context.Context, EventID) {
+	var id EventID
+	if parent, ok := FromContext(ctx); ok {
+		id = NewEventID(parent)
+	} else {
+		id = NewRootEventID()
+	}
+	return NewContext(ctx, id), id
+}
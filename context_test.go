@@ -0,0 +1,54 @@
+package lunk
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	id := NewRootEventID()
+	ctx := NewContext(context.Background(), id)
+
+	actual, ok := FromContext(ctx)
+	if !ok {
+		t.Fatal("No event ID in context")
+	}
+
+	if actual != id {
+		t.Errorf("Was %+v, but expected %+v", actual, id)
+	}
+}
+
+func TestFromContextMissing(t *testing.T) {
+	id, ok := FromContext(context.Background())
+	if ok {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestChildContext(t *testing.T) {
+	parent := NewRootEventID()
+	ctx, id := ChildContext(NewContext(context.Background(), parent))
+
+	if id.Root != parent.Root || id.Parent != parent.ID || id.ID == 0 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	actual, ok := FromContext(ctx)
+	if !ok || actual != id {
+		t.Errorf("Was %+v, but expected %+v", actual, id)
+	}
+}
+
+func TestChildContextRoot(t *testing.T) {
+	ctx, id := ChildContext(context.Background())
+
+	if id.Root == 0 || id.ID == 0 || id.Parent != 0 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	actual, ok := FromContext(ctx)
+	if !ok || actual != id {
+		t.Errorf("Was %+v, but expected %+v", actual, id)
+	}
+}
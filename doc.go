@@ -129,6 +129,30 @@
 // an optional third parameter.  A server that receives a request with this
 // header can use this to properly parent its own events.
 //
+// A sampling decision for the tree may be included as an optional fourth
+// parameter: 0 (drop), 1 (keep), or d (debug). If the event has no parent ID,
+// the third parameter is then all zeros:
+//
+//     Event-ID: d6cb1d852bbf32b6/6eeee64a8ef56225/0000000000000000/d
+//
+// Child event IDs carry their parents' decisions, and a SamplingEventLogger
+// with SetSamplingDecisions enabled logs every event of a debug tree, so a
+// single request can be traced end-to-end through sampled services.
+//
+// Lunk can also send and receive W3C Trace Context traceparent headers, in
+// which the root ID is the low 64 bits of the trace ID and the event ID is the
+// parent ID:
+//
+//     traceparent: 00-0000000000000000d6cb1d852bbf32b6-6eeee64a8ef56225-01
+//
+// Sampling decisions are sent as the traceparent's sampled flag, but since
+// other systems set that flag for their own reasons, incoming flags are only
+// used as decisions if the web package's TraceContextPropagator is configured
+// to trust them. Otherwise, they're passed along unchanged.
+//
+// Zipkin's B3 headers are supported as well. See the web package's Propagator
+// type for details.
+//
 // Event Properties
 //
 // Each event has a set of named properties, the keys and values of which are
@@ -0,0 +1,97 @@
+package lunk
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// An ErrorHandler is called with entries which could not be logged and the
+// errors which prevented them from being logged.
+type ErrorHandler func(e Entry, err error)
+
+// An ErrorHandlingEventLogger passes events through to an underlying
+// EventLogger, retrying failed attempts to log them and passing the resulting
+// errors to an ErrorHandler instead of panicking.
+//
+// If the underlying EventLogger is a FallibleEventLogger (e.g., the JSON and
+// text EventLoggers), its errors are handled; otherwise, its panics are.
+type ErrorHandlingEventLogger struct {
+	errors  uint64 // first, for 64-bit alignment
+	retries int32
+	l       EventLogger
+	h       ErrorHandler
+}
+
+// NewErrorHandlingEventLogger returns a new ErrorHandlingEventLogger, passing
+// events through to the given EventLogger and errors to the given ErrorHandler.
+// If the ErrorHandler is nil, errors are counted and otherwise ignored.
+func NewErrorHandlingEventLogger(l EventLogger, h ErrorHandler) *ErrorHandlingEventLogger {
+	return &ErrorHandlingEventLogger{
+		l: l,
+		h: h,
+	}
+}
+
+// SetRetries sets the number of times a failed attempt to log an event is
+// retried before its error is handled. Note that retries may result in
+// duplicate or partial entries if the underlying writer partially wrote the
+// failed entry. Negative values are treated as zero, and values too large to
+// count to are treated as math.MaxInt32-1.
+func (l *ErrorHandlingEventLogger) SetRetries(n int) {
+	if n < 0 {
+		n = 0
+	} else if n > math.MaxInt32-1 {
+		n = math.MaxInt32 - 1
+	}
+	atomic.StoreInt32(&l.retries, int32(n))
+}
+
+// Errors returns the number of events which could not be logged.
+func (l *ErrorHandlingEventLogger) Errors() uint64 {
+	return atomic.LoadUint64(&l.errors)
+}
+
+// Log passes the event to the underlying EventLogger, handling any error.
+func (l *ErrorHandlingEventLogger) Log(id EventID, e Event) {
+	if _, ok := l.l.(entryLogger); ok {
+		l.logEntry(NewEntry(id, e), e)
+		return
+	}
+
+	var err error
+	for i := int32(0); i <= atomic.LoadInt32(&l.retries); i++ {
+		if err = tryLog(l.l, id, e); err == nil {
+			return
+		}
+	}
+	l.handle(NewEntry(id, e), err)
+}
+
+func (l *ErrorHandlingEventLogger) logEntry(entry Entry, e Event) error {
+	var err error
+	for i := int32(0); i <= atomic.LoadInt32(&l.retries); i++ {
+		if err = logEntry(l.l, entry, e); err == nil {
+			return nil
+		}
+	}
+	l.handle(entry, err)
+	return nil
+}
+
+func (l *ErrorHandlingEventLogger) handle(e Entry, err error) {
+	atomic.AddUint64(&l.errors, 1)
+	if l.h != nil {
+		l.h(e, err)
+	}
+}
+
+// Flush flushes the underlying EventLogger, if it's a Flusher.
+func (l *ErrorHandlingEventLogger) Flush() error {
+	return flush(l.l)
+}
+
+// Close closes the underlying EventLogger, if it's a Closer, or flushes it, if
+// it's a Flusher.
+func (l *ErrorHandlingEventLogger) Close() error {
+	return closeOrFlush(l.l)
+}
@@ -0,0 +1,135 @@
+package lunk
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+var _ EventLogger = &ErrorHandlingEventLogger{}
+
+func TestErrorHandlingEventLogger(t *testing.T) {
+	w := &failingWriter{failures: 1}
+	var handled []Entry
+	l := NewErrorHandlingEventLogger(NewJSONEventLogger(w), func(e Entry, err error) {
+		if err != errWriteFailed {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		handled = append(handled, e)
+	})
+
+	id := NewRootEventID()
+	l.Log(id, mockEvent{Example: "whee"})
+
+	if l.Errors() != 1 {
+		t.Errorf("Unexpected number of errors: %d", l.Errors())
+	}
+
+	if len(handled) != 1 {
+		t.Fatalf("Unexpected handled entries: %+v", handled)
+	}
+
+	if handled[0].EventID != id || handled[0].Properties["example"] != "whee" {
+		t.Errorf("Unexpected entry: %+v", handled[0])
+	}
+
+	l.Log(id, mockEvent{Example: "whee"})
+
+	if l.Errors() != 1 {
+		t.Errorf("Unexpected number of errors: %d", l.Errors())
+	}
+
+	if w.writes != 1 {
+		t.Errorf("Unexpected number of writes: %d", w.writes)
+	}
+}
+
+func TestErrorHandlingEventLoggerRetries(t *testing.T) {
+	w := &failingWriter{failures: 2}
+	l := NewErrorHandlingEventLogger(NewTextEventLogger(w), nil)
+	l.SetRetries(2)
+
+	l.Log(NewRootEventID(), mockEvent{Example: "whee"})
+
+	if l.Errors() != 0 {
+		t.Errorf("Unexpected number of errors: %d", l.Errors())
+	}
+
+	if w.writes != 1 {
+		t.Errorf("Unexpected number of writes: %d", w.writes)
+	}
+}
+
+func TestErrorHandlingEventLoggerNegativeRetries(t *testing.T) {
+	w := &failingWriter{}
+	l := NewErrorHandlingEventLogger(NewTextEventLogger(w), nil)
+	l.SetRetries(-1)
+
+	l.Log(NewRootEventID(), mockEvent{Example: "whee"})
+
+	if l.Errors() != 0 {
+		t.Errorf("Unexpected number of errors: %d", l.Errors())
+	}
+
+	if w.writes != 1 {
+		t.Errorf("Unexpected number of writes: %d", w.writes)
+	}
+}
+
+func TestErrorHandlingEventLoggerHugeRetries(t *testing.T) {
+	w := &failingWriter{failures: 2}
+	l := NewErrorHandlingEventLogger(NewTextEventLogger(w), nil)
+	l.SetRetries(math.MaxInt)
+
+	l.Log(NewRootEventID(), mockEvent{Example: "whee"})
+
+	if l.Errors() != 0 {
+		t.Errorf("Unexpected number of errors: %d", l.Errors())
+	}
+
+	if w.writes != 1 {
+		t.Errorf("Unexpected number of writes: %d", w.writes)
+	}
+
+	if l.retries != math.MaxInt32-1 {
+		t.Errorf("Was %d, but expected %d", l.retries, math.MaxInt32-1)
+	}
+}
+
+func TestErrorHandlingEventLoggerPanics(t *testing.T) {
+	var handled []Entry
+	l := NewErrorHandlingEventLogger(panickingLogger{}, func(e Entry, err error) {
+		if err.Error() != "woo" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		handled = append(handled, e)
+	})
+	l.SetRetries(3)
+
+	l.Log(NewRootEventID(), mockEvent{Example: "whee"})
+
+	if l.Errors() != 1 {
+		t.Errorf("Unexpected number of errors: %d", l.Errors())
+	}
+
+	if len(handled) != 1 {
+		t.Errorf("Unexpected handled entries: %+v", handled)
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter fails a given number of writes before succeeding.
+type failingWriter struct {
+	failures int
+	writes   int
+}
+
+func (w *failingWriter) Write(a []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+		return 0, errWriteFailed
+	}
+	w.writes++
+	return len(a), nil
+}
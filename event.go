@@ -31,12 +31,66 @@ type EventID struct {
 
 	// Parent is the ID of the parent event, if any.
 	Parent ID `json:"parent,omitempty"`
+
+	// Sampling is the sampling decision for the tree, if any. It is passed
+	// along with the IDs, but isn't part of an entry.
+	Sampling SamplingDecision `json:"-"`
+}
+
+// A SamplingDecision is a decision as to whether or not the events in a tree
+// should be logged, made once for the whole tree and passed along with its
+// event IDs, so that every process involved logs the same trees.
+type SamplingDecision uint8
+
+const (
+	// SamplingUndecided means no decision has been made, and each process
+	// decides for itself.
+	SamplingUndecided SamplingDecision = iota
+
+	// SamplingDrop means the tree's events should be dropped.
+	SamplingDrop
+
+	// SamplingKeep means the tree's events should be logged.
+	SamplingKeep
+
+	// SamplingDebug means the tree's events should be logged, regardless of
+	// any sampling rates, e.g. because a developer requested it.
+	SamplingDebug
+)
+
+// String returns the decision's encoding in Event-ID headers: "0" for
+// SamplingDrop, "1" for SamplingKeep, "d" for SamplingDebug, or an empty
+// string for SamplingUndecided.
+func (d SamplingDecision) String() string {
+	switch d {
+	case SamplingDrop:
+		return "0"
+	case SamplingKeep:
+		return "1"
+	case SamplingDebug:
+		return "d"
+	}
+	return ""
 }
 
 // String returns the EventID as a slash-separated, set of hex-encoded
-// parameters (root, ID, parent). If the EventID has no parent, that value is
-// elided.
+// parameters (root, ID, parent), followed by the sampling decision, if any. If
+// the EventID has no parent or sampling decision, those values are elided; if
+// it has a sampling decision but no parent, the parent is encoded as zero.
 func (id EventID) String() string {
+	if id.Sampling != SamplingUndecided {
+		return fmt.Sprintf(
+			"%s%s%s%s%s%s%s",
+			id.Root,
+			EventIDDelimiter,
+			id.ID,
+			EventIDDelimiter,
+			id.Parent,
+			EventIDDelimiter,
+			id.Sampling,
+		)
+	}
+
 	if id.Parent == 0 {
 		return fmt.Sprintf("%s%s%s", id.Root, EventIDDelimiter, id.ID)
 	}
@@ -69,12 +123,14 @@ func NewRootEventID() EventID {
 }
 
 // NewEventID returns a new ID for an event which is the child of the given
-// parent ID. This should be used to track causal relationships between events.
+// parent ID, with the parent's sampling decision. This should be used to track
+// causal relationships between events.
 func NewEventID(parent EventID) EventID {
 	return EventID{
-		Root:   parent.Root,
-		ID:     generateID(),
-		Parent: parent.ID,
+		Root:     parent.Root,
+		ID:       generateID(),
+		Parent:   parent.ID,
+		Sampling: parent.Sampling,
 	}
 }
 
@@ -87,7 +143,7 @@ const (
 // ParseEventID parses the given string as a slash-separated set of parameters.
 func ParseEventID(s string) (*EventID, error) {
 	parts := strings.Split(s, EventIDDelimiter)
-	if len(parts) != 2 && len(parts) != 3 {
+	if len(parts) < 2 || len(parts) > 4 {
 		return nil, ErrBadEventID
 	}
 
@@ -102,7 +158,7 @@ func ParseEventID(s string) (*EventID, error) {
 	}
 
 	var parent ID
-	if len(parts) == 3 {
+	if len(parts) >= 3 {
 		i, err := ParseID(parts[2])
 		if err != nil {
 			return nil, ErrBadEventID
@@ -110,10 +166,25 @@ func ParseEventID(s string) (*EventID, error) {
 		parent = i
 	}
 
+	var sampling SamplingDecision
+	if len(parts) == 4 {
+		switch parts[3] {
+		case "0":
+			sampling = SamplingDrop
+		case "1":
+			sampling = SamplingKeep
+		case "d":
+			sampling = SamplingDebug
+		default:
+			return nil, ErrBadEventID
+		}
+	}
+
 	return &EventID{
-		Root:   root,
-		ID:     id,
-		Parent: parent,
+		Root:     root,
+		ID:       id,
+		Parent:   parent,
+		Sampling: sampling,
 	}, nil
 }
 
@@ -72,6 +72,27 @@ func TestEventIDStringWithParent(t *testing.T) {
 	}
 }
 
+func TestEventIDStringWithSampling(t *testing.T) {
+	for expected, id := range map[string]EventID{
+		"0000000000000064/000000000000012c/0000000000000000/1": {Root: 100, ID: 300, Sampling: SamplingKeep},
+		"0000000000000064/000000000000012c/00000000000000c8/0": {Root: 100, ID: 300, Parent: 200, Sampling: SamplingDrop},
+		"0000000000000064/000000000000012c/00000000000000c8/d": {Root: 100, ID: 300, Parent: 200, Sampling: SamplingDebug},
+	} {
+		if actual := id.String(); actual != expected {
+			t.Errorf("Was %#v, but expected %#v", actual, expected)
+		}
+	}
+}
+
+func TestNewEventIDSampling(t *testing.T) {
+	root := NewRootEventID()
+	root.Sampling = SamplingDebug
+
+	if id := NewEventID(root); id.Sampling != SamplingDebug {
+		t.Errorf("Was %v, but expected %v", id.Sampling, SamplingDebug)
+	}
+}
+
 func TestEventIDFormat(t *testing.T) {
 	id := EventID{
 		Root: 100,
@@ -134,6 +155,45 @@ func TestParseEventIDWithParent(t *testing.T) {
 	}
 }
 
+func TestParseEventIDWithSampling(t *testing.T) {
+	for s, expected := range map[string]EventID{
+		"0000000000000064/000000000000012c/0000000000000000/0": {Root: 100, ID: 300, Sampling: SamplingDrop},
+		"0000000000000064/000000000000012c/0000000000000096/1": {Root: 100, ID: 300, Parent: 150, Sampling: SamplingKeep},
+		"0000000000000064/000000000000012c/0000000000000096/d": {Root: 100, ID: 300, Parent: 150, Sampling: SamplingDebug},
+	} {
+		id, err := ParseEventID(s)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if *id != expected {
+			t.Errorf("Was %+v, but expected %+v", *id, expected)
+		}
+
+		if id.String() != s {
+			t.Errorf("Was %#v, but expected %#v", id.String(), s)
+		}
+	}
+}
+
+func TestParseEventIDBadSampling(t *testing.T) {
+	for _, s := range []string{
+		"0000000000000064/000000000000012c/0000000000000096/",
+		"0000000000000064/000000000000012c/0000000000000096/2",
+		"0000000000000064/000000000000012c/0000000000000096/1/1",
+	} {
+		id, err := ParseEventID(s)
+
+		if id != nil {
+			t.Errorf("Unexpected event ID: %+v", id)
+		}
+
+		if err != ErrBadEventID {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
 func TestParseEventIDMalformed(t *testing.T) {
 	id, err := ParseEventID(`0000000000000064000000000000012c`)
 
@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 )
 
 // An EventLogger logs events and their metadata.
@@ -18,18 +21,73 @@ type EventLogger interface {
 	Log(id EventID, e Event)
 }
 
+// A FallibleEventLogger is an EventLogger which can report failures to log
+// events to its caller.
+type FallibleEventLogger interface {
+	EventLogger
+
+	// TryLog adds the given event to the log stream, returning an error if
+	// the event could not be logged.
+	TryLog(id EventID, e Event) error
+}
+
+// A Flusher is an EventLogger or EntryRecorder which buffers entries.
+type Flusher interface {
+	// Flush writes any buffered entries.
+	Flush() error
+}
+
+// A Closer is an EventLogger or EntryRecorder which must be closed when it is
+// no longer needed. EventLoggers and EntryRecorders which wrap others close
+// those as well, so a single call to Close at shutdown flushes and closes
+// everything.
+type Closer interface {
+	// Close flushes any buffered entries and releases any resources.
+	Close() error
+}
+
+// flush flushes the given EventLogger or EntryRecorder, if it's a Flusher.
+func flush(v interface{}) error {
+	if f, ok := v.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// closeOrFlush closes the given EventLogger or EntryRecorder, if it's a Closer,
+// or flushes it, if it's a Flusher.
+func closeOrFlush(v interface{}) error {
+	if c, ok := v.(Closer); ok {
+		return c.Close()
+	}
+	return flush(v)
+}
+
 // NewJSONEventLogger returns an EventLogger which writes entries as streaming
-// JSON to the given writer.
+// JSON to the given writer. The returned EventLogger is a FallibleEventLogger;
+// its Log method panics if the entry cannot be written. It is also a Flusher
+// and a Closer, which flush the writer if it has a Flush method and close the
+// writer if it is an io.Closer.
 func NewJSONEventLogger(w io.Writer) EventLogger {
-	return jsonEventLogger{json.NewEncoder(w)}
+	return jsonEventLogger{w: w}
 }
 
 // NewTextEventLogger returns an EventLogger which writes entries as single
-// lines of attr="value" formatted text.
+// lines of attr="value" formatted text. Attribute names containing spaces,
+// quotes, or '=' (e.g., from map keys) are quoted as well. The returned EventLogger is a
+// FallibleEventLogger; its Log method panics if the entry cannot be written. It
+// is also a Flusher and a Closer, as with NewJSONEventLogger.
 func NewTextEventLogger(w io.Writer) EventLogger {
 	return textEventLogger{w: w}
 }
 
+// SampleRateProperty is the property in which a SamplingEventLogger records
+// the probability with which an event was sampled to meet its schema's target
+// rate (e.g., "0.25" if one in four events was logged). Each logged event
+// represents 1/p events, so counts can be re-weighted accordingly, as the stats
+// package's Aggregator does.
+const SampleRateProperty = "sample_rate"
+
 // A SamplingEventLogger logs a uniform sampling of events, if configured to do
 // so.
 type SamplingEventLogger struct {
@@ -37,9 +95,19 @@ type SamplingEventLogger struct {
 	r         *rand.Rand
 	rates     map[string]float64
 	rootRates map[ID]float64
+	targets   map[string]*targetRate
+	config    *samplingConfig
+	now       func() time.Time
 	m         *sync.Mutex
 }
 
+// samplingConfig is the mutable configuration of a SamplingEventLogger, which
+// is shared by its copies.
+type samplingConfig struct {
+	deterministic bool
+	decisions     bool
+}
+
 // NewSamplingEventLogger returns a new SamplingEventLogger, passing events
 // through to the given EventLogger.
 func NewSamplingEventLogger(l EventLogger) *SamplingEventLogger {
@@ -48,6 +116,9 @@ func NewSamplingEventLogger(l EventLogger) *SamplingEventLogger {
 		r:         rand.New(rand.NewSource(time.Now().UnixNano())),
 		rates:     make(map[string]float64),
 		rootRates: make(map[ID]float64),
+		targets:   make(map[string]*targetRate),
+		config:    new(samplingConfig),
+		now:       time.Now,
 		m:         new(sync.Mutex),
 	}
 }
@@ -88,41 +159,363 @@ func (l SamplingEventLogger) UnsetSchemaSampleRate(schema string) {
 	delete(l.rates, schema)
 }
 
+// SetSchemaTargetRate sets a target rate, in events per second, for events
+// with the given schema. Rather than being sampled with a fixed probability,
+// they are sampled with a probability which is continuously adjusted to the
+// observed rate of events, so that roughly the target rate of events is logged
+// regardless of load. Each logged event records the probability with which it
+// was sampled in its SampleRateProperty property. A schema's target rate takes
+// precedence over its sampling rate, but not over a root ID's sampling rate.
+func (l SamplingEventLogger) SetSchemaTargetRate(schema string, perSecond float64) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if t, ok := l.targets[schema]; ok {
+		t.target = perSecond
+		return
+	}
+	l.targets[schema] = &targetRate{target: perSecond}
+}
+
+// UnsetSchemaTargetRate removes any target rate for events with the given
+// schema.
+func (l SamplingEventLogger) UnsetSchemaTargetRate(schema string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	delete(l.targets, schema)
+}
+
+// SetDeterministicSampling sets whether or not the decision to log or drop an
+// event is a deterministic function of its root ID, rather than random. With
+// deterministic sampling, all of the events in a tree which are sampled at the
+// same rate are either logged or dropped together, even by separate processes,
+// so sampled trees are complete. See RootSampled for details.
+func (l SamplingEventLogger) SetDeterministicSampling(deterministic bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.config.deterministic = deterministic
+}
+
+// SetSamplingDecisions sets whether or not the sampling decisions carried by
+// event IDs are respected. If they are, events with SamplingKeep or
+// SamplingDebug decisions are always logged, events with SamplingDrop decisions
+// are always dropped, and only events with SamplingUndecided decisions are
+// sampled at the configured rates. This allows a request to be forced through
+// every sampling stage, e.g. by sending it with a debug Event-ID header.
+func (l SamplingEventLogger) SetSamplingDecisions(respect bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.config.decisions = respect
+}
+
 // Log passes the event to the underlying EventLogger, probabilistically
 // dropping some events.
 func (l SamplingEventLogger) Log(id EventID, e Event) {
 	l.m.Lock()
 	defer l.m.Unlock()
 
+	ok, p := l.sampled(id, e.Schema())
+	if !ok {
+		return
+	}
+
+	if p > 0 {
+		e = sampledEvent{Event: e, p: p}
+	}
+	l.l.Log(id, e)
+}
+
+func (l SamplingEventLogger) logEntry(entry Entry, e Event) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	ok, p := l.sampled(entry.EventID, entry.Schema)
+	if !ok {
+		return nil
+	}
+
+	if p > 0 {
+		props := make(map[string]string, len(entry.Properties)+1)
+		for k, v := range entry.Properties {
+			props[k] = v
+		}
+		props[SampleRateProperty] = formatSampleRate(p)
+		entry.Properties = props
+		e = sampledEvent{Event: e, p: p}
+	}
+	return logEntry(l.l, entry, e)
+}
+
+// sampled returns whether or not an event with the given ID and schema should
+// be logged and, if it was sampled to meet a target rate, the probability with
+// which it was sampled. l.m must be held.
+func (l SamplingEventLogger) sampled(id EventID, schema string) (bool, float64) {
+	if l.config.decisions {
+		switch id.Sampling {
+		case SamplingKeep, SamplingDebug:
+			return true, 0
+		case SamplingDrop:
+			return false, 0
+		}
+	}
+
+	var adapted float64
 	r, ok := l.rootRates[id.Root]
 	if !ok {
-		r, ok = l.rates[e.Schema()]
+		if t, found := l.targets[schema]; found {
+			r, ok = t.observe(l.now()), true
+			adapted = r
+		}
 	}
 
-	if ok && r < l.r.Float64() {
-		return
+	if !ok {
+		r, ok = l.rates[schema]
 	}
 
-	l.l.Log(id, e)
+	if !ok {
+		return true, 0
+	}
+
+	if l.config.deterministic {
+		return RootSampled(id.Root, r), adapted
+	}
+	return r >= l.r.Float64(), adapted
+}
+
+const (
+	// targetInterval is the interval over which a targetRate counts events.
+	targetInterval = time.Second
+
+	// targetWeight is the weight given to each interval's observed rate in a
+	// targetRate's moving average.
+	targetWeight = 0.3
+)
+
+// A targetRate adjusts the probability with which events are sampled to keep
+// the rate of sampled events near a target, using an exponentially-weighted
+// moving average of the observed rate of events.
+type targetRate struct {
+	target float64   // the target rate, in events per second
+	rate   float64   // the moving average of the observed rate
+	primed bool      // whether or not rate has been measured
+	start  time.Time // the start of the current interval
+	count  float64   // the number of events in the current interval
+}
+
+// observe counts an event at the given time, returning the probability with
+// which it should be sampled.
+func (t *targetRate) observe(now time.Time) float64 {
+	if t.start.IsZero() {
+		t.start = now
+	}
+
+	if d := now.Sub(t.start); d >= targetInterval {
+		r := t.count / d.Seconds()
+		if t.primed {
+			// weight the interval by its length, so idle periods decay the
+			// average as many short intervals would
+			w := 1 - math.Pow(1-targetWeight, float64(d)/float64(targetInterval))
+			t.rate += w * (r - t.rate)
+		} else {
+			t.rate, t.primed = r, true
+		}
+		t.start, t.count = now, 0
+	}
+	t.count++
+
+	// bursts are limited by the rate observed so far in the current interval
+	rate := math.Max(t.rate, t.count/targetInterval.Seconds())
+	if rate <= t.target {
+		return 1
+	}
+	return t.target / rate
+}
+
+// sampledEvent is an event which was sampled to meet a target rate. Its
+// properties are those of the wrapped event, plus the sampling probability.
+type sampledEvent struct {
+	Event
+	p float64
+}
+
+func (e sampledEvent) flatten(prefix string, f func(k, v string)) {
+	flattenValue(prefix, reflect.ValueOf(e.Event), f)
+	f(nest(prefix, SampleRateProperty), formatSampleRate(e.p))
+}
+
+func formatSampleRate(p float64) string {
+	return strconv.FormatFloat(p, 'g', 6, 64)
+}
+
+// RootSampled returns whether or not the tree with the given root ID is sampled
+// at the given rate, which should be between 0.0 (no trees sampled) and 1.0
+// (all trees sampled), inclusive. A tree sampled at one rate is also sampled at
+// all higher rates.
+//
+// The root ID is hashed with the SplitMix64 finalizer, and the tree is sampled
+// if the top 53 bits of the hash, as a fraction of 2^53, are less than the
+// rate. Other implementations can make the same decisions by doing the same.
+func RootSampled(root ID, p float64) bool {
+	return float64(splitMix64(uint64(root))>>11)/(1<<53) < p
+}
+
+// splitMix64 is the finalizer of the SplitMix64 generator, which mixes the
+// bits of its input.
+func splitMix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Flush flushes the underlying EventLogger, if it's a Flusher.
+func (l SamplingEventLogger) Flush() error {
+	return flush(l.l)
+}
+
+// Close closes the underlying EventLogger, if it's a Closer, or flushes it, if
+// it's a Flusher.
+func (l SamplingEventLogger) Close() error {
+	return closeOrFlush(l.l)
+}
+
+// An entryLogger is an EventLogger which can log pre-built entries, preserving
+// their metadata. Along with the entry, it's given the event the entry was
+// built from, which it passes on to any EventLoggers it wraps which can't log
+// pre-built entries.
+type entryLogger interface {
+	logEntry(entry Entry, e Event) error
+}
+
+// logEntry logs a pre-built entry to the given EventLogger. If the EventLogger
+// can't log pre-built entries, the event the entry was built from is logged
+// instead, and the EventLogger creates its own metadata for it.
+func logEntry(l EventLogger, entry Entry, e Event) error {
+	if el, ok := l.(entryLogger); ok {
+		return el.logEntry(entry, e)
+	}
+	return tryLog(l, entry.EventID, e)
+}
+
+// tryLog logs an event to the given EventLogger, returning an error if the event
+// could not be logged. If the EventLogger isn't a FallibleEventLogger, panics
+// are recovered and returned as errors.
+func tryLog(l EventLogger, id EventID, e Event) (err error) {
+	if fl, ok := l.(FallibleEventLogger); ok {
+		return fl.TryLog(id, e)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	l.Log(id, e)
+	return nil
+}
+
+// entryEvent is an event with the schema and properties of an entry.
+type entryEvent struct {
+	e Entry
+}
+
+func (e entryEvent) Schema() string {
+	return e.e.Schema
+}
+
+func (e entryEvent) flatten(prefix string, f func(k, v string)) {
+	for k, v := range e.e.Properties {
+		f(nest(prefix, k), v)
+	}
+}
+
+// flushWriter flushes the given writer, if it has a Flush method (e.g., a
+// bufio.Writer).
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// closeWriter flushes the given writer, then closes it, if it's an io.Closer.
+func closeWriter(w io.Writer) error {
+	if err := flushWriter(w); err != nil {
+		return err
+	}
+
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 type jsonEventLogger struct {
-	*json.Encoder
+	w io.Writer
+}
+
+func (l jsonEventLogger) Flush() error {
+	return flushWriter(l.w)
+}
+
+func (l jsonEventLogger) Close() error {
+	return closeWriter(l.w)
 }
 
 func (l jsonEventLogger) Log(id EventID, e Event) {
-	if err := l.Encode(NewEntry(id, e)); err != nil {
+	if err := l.TryLog(id, e); err != nil {
 		panic(err)
 	}
 }
 
+func (l jsonEventLogger) TryLog(id EventID, e Event) error {
+	return l.logEntry(NewEntry(id, e), e)
+}
+
+func (l jsonEventLogger) logEntry(entry Entry, _ Event) error {
+	// json.Encoder retains write errors, so encode each entry separately
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
 type textEventLogger struct {
 	w io.Writer
 }
 
+func (l textEventLogger) Flush() error {
+	return flushWriter(l.w)
+}
+
+func (l textEventLogger) Close() error {
+	return closeWriter(l.w)
+}
+
 func (l textEventLogger) Log(id EventID, e Event) {
-	entry := NewEntry(id, e)
+	if err := l.TryLog(id, e); err != nil {
+		panic(err)
+	}
+}
+
+func (l textEventLogger) TryLog(id EventID, e Event) error {
+	return l.logEntry(NewEntry(id, e), e)
+}
 
+func (l textEventLogger) logEntry(entry Entry, _ Event) error {
 	props := []string{
 		fmt.Sprintf("time=%s", strconv.Quote(entry.Time.Format(time.RFC3339))),
 		fmt.Sprintf("host=%s", strconv.Quote(entry.Host)),
@@ -139,13 +532,23 @@ func (l textEventLogger) Log(id EventID, e Event) {
 	}
 
 	for _, k := range sortedKeys(entry.Properties) {
-		s := fmt.Sprintf("p:%s=%s", k, strconv.Quote(entry.Properties[k]))
+		s := fmt.Sprintf("%s=%s", textKey("p:"+k), strconv.Quote(entry.Properties[k]))
 		props = append(props, s)
 	}
 
-	if _, err := fmt.Fprintln(l.w, strings.Join(props, " ")); err != nil {
-		panic(err)
+	_, err := fmt.Fprintln(l.w, strings.Join(props, " "))
+	return err
+}
+
+// textKey returns the given attribute name, quoted if it contains characters
+// which would otherwise make the line ambiguous (e.g., spaces or '=').
+func textKey(k string) string {
+	if strings.IndexFunc(k, func(r rune) bool {
+		return r == '=' || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(k)
 	}
+	return k
 }
 
 func sortedKeys(m map[string]string) []string {
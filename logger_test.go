@@ -1,10 +1,13 @@
 package lunk
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"math"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -94,6 +97,23 @@ func TestTextEventLoggerLog(t *testing.T) {
 	}
 }
 
+func TestTextEventLoggerQuotedKeys(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := NewTextEventLogger(buf)
+	logger.Log(EventID{Root: 100, ID: 200}, mapEvent{"a=b": "x", "c": "y"})
+
+	expected := ` "p:a=b"="x" p:c="y"` + "\n"
+	if actual := buf.String(); !strings.HasSuffix(actual, expected) {
+		t.Errorf("Was `%s` but expected a suffix of `%s`", actual, expected)
+	}
+}
+
+type mapEvent map[string]string
+
+func (mapEvent) Schema() string {
+	return "map"
+}
+
 func TestTextEventLoggerLogElidedParentID(t *testing.T) {
 	ev := mockEvent{Example: "whee"}
 
@@ -123,6 +143,86 @@ func TestTextEventLoggerLogElidedParentID(t *testing.T) {
 	}
 }
 
+func TestJSONEventLoggerTryLog(t *testing.T) {
+	logger := NewJSONEventLogger(&failingWriter{failures: 1})
+
+	err := logger.(FallibleEventLogger).TryLog(NewRootEventID(), mockEvent{})
+	if err != errWriteFailed {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestJSONEventLoggerLogPanics(t *testing.T) {
+	logger := NewJSONEventLogger(&failingWriter{failures: 1})
+
+	defer func() {
+		if r := recover(); r != errWriteFailed {
+			t.Errorf("Unexpected panic: %v", r)
+		}
+	}()
+
+	logger.Log(NewRootEventID(), mockEvent{})
+}
+
+func TestTextEventLoggerTryLog(t *testing.T) {
+	logger := NewTextEventLogger(&failingWriter{failures: 1})
+
+	err := logger.(FallibleEventLogger).TryLog(NewRootEventID(), mockEvent{})
+	if err != errWriteFailed {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestJSONEventLoggerFlush(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := NewJSONEventLogger(bufio.NewWriter(buf))
+	logger.Log(NewRootEventID(), mockEvent{})
+
+	if buf.Len() != 0 {
+		t.Fatalf("Unexpectedly unbuffered write: %s", buf.String())
+	}
+
+	if err := logger.(Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() == 0 {
+		t.Errorf("Unexpectedly empty buffer")
+	}
+}
+
+func TestTextEventLoggerClose(t *testing.T) {
+	w := &closingWriter{}
+	logger := NewTextEventLogger(w)
+
+	if err := logger.(Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.closed {
+		t.Errorf("Writer wasn't closed")
+	}
+}
+
+func TestSamplingEventLoggerClose(t *testing.T) {
+	w := &closingWriter{}
+	buf := bufio.NewWriter(w)
+	sl := NewSamplingEventLogger(NewTextEventLogger(buf))
+	sl.Log(NewRootEventID(), mockEvent{})
+
+	if err := sl.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.writes != 1 {
+		t.Errorf("Unexpected number of writes: %d", w.writes)
+	}
+
+	if err := sl.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSamplingEventLogger(t *testing.T) {
 	e := mockEvent{}
 	l := fakeLogger{}
@@ -177,6 +277,203 @@ func TestSamplingEventLoggerRootRates(t *testing.T) {
 	}
 }
 
+func TestSamplingEventLoggerDeterministic(t *testing.T) {
+	e := mockEvent{}
+	a, b := fakeLogger{}, fakeLogger{}
+	sa, sb := NewSamplingEventLogger(&a), NewSamplingEventLogger(&b)
+	for _, sl := range []*SamplingEventLogger{sa, sb} {
+		sl.SetSchemaSampleRate(e.Schema(), 0.25)
+		sl.SetDeterministicSampling(true)
+	}
+
+	for i := 0; i < 10000; i++ {
+		root := ID(i * 7919)
+		sa.Log(EventID{Root: root, ID: ID(i)}, e)
+		sa.Log(EventID{Root: root, ID: ID(i + 10000)}, e)
+		sb.Log(EventID{Root: root, ID: ID(i + 20000)}, e)
+	}
+
+	if 2*2250 > len(a.events) {
+		t.Errorf("Unexpectedly few logged events: %d", len(a.events))
+	}
+
+	if len(a.events) > 2*2750 {
+		t.Errorf("Unexpectedly many logged events: %d", len(a.events))
+	}
+
+	// both events in each tree were logged, and so were the other logger's
+	if len(a.events) != 2*len(b.events) {
+		t.Fatalf("Was %d events, but expected %d", len(a.events), 2*len(b.events))
+	}
+
+	for i, l := range b.events {
+		if a.events[2*i].id.Root != l.id.Root || a.events[2*i+1].id.Root != l.id.Root {
+			t.Errorf("Root %v was not sampled consistently", l.id.Root)
+		}
+	}
+}
+
+func TestSamplingEventLoggerDecisions(t *testing.T) {
+	e := mockEvent{}
+	l := fakeLogger{}
+	sl := NewSamplingEventLogger(&l)
+	sl.SetSchemaSampleRate(e.Schema(), 0)
+
+	debug := EventID{Root: 1, ID: 2, Sampling: SamplingDebug}
+	sl.Log(debug, e)
+	if len(l.events) != 0 {
+		t.Fatalf("Was %d events, but expected %d", len(l.events), 0)
+	}
+
+	sl.SetSamplingDecisions(true)
+	sl.SetSchemaSampleRate(e.Schema(), 1)
+	sl.Log(EventID{Root: 1, ID: 3, Sampling: SamplingDrop}, e)
+	sl.Log(EventID{Root: 1, ID: 4}, e)
+
+	sl.SetSchemaSampleRate(e.Schema(), 0)
+	sl.Log(debug, e)
+	sl.Log(NewEventID(debug), e)
+	sl.Log(EventID{Root: 1, ID: 5, Sampling: SamplingKeep}, e)
+	sl.Log(EventID{Root: 1, ID: 6}, e)
+
+	var actual []ID
+	for _, le := range l.events {
+		actual = append(actual, le.id.ID)
+	}
+
+	if len(actual) != 4 || actual[0] != 4 || actual[1] != 2 || actual[3] != 5 {
+		t.Errorf("Was %v, but expected [4 2 <child> 5]", actual)
+	}
+}
+
+func TestSamplingEventLoggerTargetRate(t *testing.T) {
+	e := mockEvent{}
+	l := fakeLogger{}
+	sl := NewSamplingEventLogger(&l)
+	sl.SetSchemaSampleRate(e.Schema(), 0)
+	sl.SetSchemaTargetRate(e.Schema(), 10)
+
+	// 100 events per second for a minute
+	now := time.Date(2014, 1, 2, 3, 4, 5, 0, time.UTC)
+	sl.now = func() time.Time { return now }
+	for i := 0; i < 6000; i++ {
+		sl.Log(EventID{Root: ID(i + 1), ID: ID(i + 1)}, e)
+		now = now.Add(10 * time.Millisecond)
+	}
+
+	if 450 > len(l.events) {
+		t.Errorf("Unexpectedly few logged events: %d", len(l.events))
+	}
+
+	if len(l.events) > 750 {
+		t.Errorf("Unexpectedly many logged events: %d", len(l.events))
+	}
+
+	last := NewEntry(l.events[len(l.events)-1].id, l.events[len(l.events)-1].e)
+	if v := last.Properties["sample_rate"]; v != "0.1" {
+		t.Errorf("Was %#v, but expected %#v", v, "0.1")
+	}
+
+	if last.Schema != e.Schema() {
+		t.Errorf("Was %#v, but expected %#v", last.Schema, e.Schema())
+	}
+}
+
+func TestSamplingEventLoggerTargetRateEntries(t *testing.T) {
+	l := fakeLogger{}
+	sl := NewSamplingEventLogger(&l)
+	sl.SetSchemaTargetRate("example", 1000)
+
+	entry := Entry{
+		Schema:     "example",
+		Properties: map[string]string{"woo": "yay"},
+	}
+	if err := NewLoggingEntryRecorder(sl).Record(entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	actual := NewEntry(l.events[0].id, l.events[0].e).Properties
+	expected := map[string]string{"woo": "yay", "sample_rate": "1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+
+	if len(entry.Properties) != 1 {
+		t.Errorf("Entry's properties were modified: %#v", entry.Properties)
+	}
+}
+
+func TestTargetRate(t *testing.T) {
+	tr := targetRate{target: 10}
+	now := time.Date(2014, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// the first interval is limited by the events observed so far
+	for i := 0; i < 100; i++ {
+		if p, expected := tr.observe(now), math.Min(1, 10/float64(i+1)); p != expected {
+			t.Fatalf("Was %v, but expected %v", p, expected)
+		}
+		now = now.Add(10 * time.Millisecond)
+	}
+
+	// afterwards, by the moving average of previous intervals
+	if p := tr.observe(now); p != 0.1 {
+		t.Errorf("Was %v, but expected %v", p, 0.1)
+	}
+
+	// which decays when idle
+	now = now.Add(time.Minute)
+	if p := tr.observe(now); p != 1 {
+		t.Errorf("Was %v, but expected %v", p, 1)
+	}
+}
+
+func TestSamplingEventLoggerUnsetTargetRate(t *testing.T) {
+	e := mockEvent{}
+	l := fakeLogger{}
+	sl := NewSamplingEventLogger(&l)
+	sl.SetSchemaTargetRate(e.Schema(), 0)
+	sl.Log(NewRootEventID(), e)
+	sl.UnsetSchemaTargetRate(e.Schema())
+	sl.Log(NewRootEventID(), e)
+
+	if len(l.events) != 1 {
+		t.Fatalf("Was %d events, but expected %d", len(l.events), 1)
+	}
+
+	if _, ok := l.events[0].e.(sampledEvent); ok {
+		t.Errorf("Unexpected sampled event: %#v", l.events[0].e)
+	}
+}
+
+func TestRootSampled(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		root := ID(i)
+		if RootSampled(root, 0) {
+			t.Errorf("%v was sampled at 0.0", root)
+		}
+
+		if !RootSampled(root, 1) {
+			t.Errorf("%v wasn't sampled at 1.0", root)
+		}
+
+		if RootSampled(root, 0.1) && !RootSampled(root, 0.5) {
+			t.Errorf("%v was sampled at 0.1 but not 0.5", root)
+		}
+	}
+
+	// fixed values, so other implementations can be checked against these
+	for root, expected := range map[ID]bool{
+		0x0000000000000001: true,
+		0x0000000000000002: false,
+		0xd6cb1d852bbf32b6: true,
+		0x6eeee64a8ef56225: true,
+	} {
+		if actual := RootSampled(root, 0.5); actual != expected {
+			t.Errorf("%v was %v, but expected %v", root, actual, expected)
+		}
+	}
+}
+
 type fakeLogging struct {
 	id EventID
 	e  Event
@@ -190,6 +487,21 @@ func (l *fakeLogger) Log(id EventID, e Event) {
 	l.events = append(l.events, fakeLogging{id: id, e: e})
 }
 
+type closingWriter struct {
+	writes int
+	closed bool
+}
+
+func (w *closingWriter) Write(a []byte) (int, error) {
+	w.writes++
+	return len(a), nil
+}
+
+func (w *closingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
 type nullWriter struct{}
 
 func (nullWriter) Write(a []byte) (int, error) {
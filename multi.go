@@ -0,0 +1,61 @@
+package lunk
+
+import "errors"
+
+// NewMultiEventLogger returns an EventLogger which logs each event to all of the
+// given EventLoggers. The entry for each event is created once and shared by all
+// of this package's EventLoggers, so they record identical metadata. Any other
+// EventLoggers are passed the original event, and create their own metadata.
+//
+// A failure or panic in one EventLogger does not prevent the event from being
+// logged to the others. The returned EventLogger is a FallibleEventLogger whose
+// TryLog method returns the errors of all the EventLoggers which failed, and
+// whose Log method panics with those errors once the event has been passed to
+// all of the EventLoggers. Wrap it, or any of the given EventLoggers, with an
+// ErrorHandlingEventLogger to handle those errors instead. It is also a Flusher
+// and a Closer, which flush and close all of the given EventLoggers.
+func NewMultiEventLogger(loggers ...EventLogger) EventLogger {
+	return multiEventLogger(loggers)
+}
+
+type multiEventLogger []EventLogger
+
+func (l multiEventLogger) Log(id EventID, e Event) {
+	if err := l.TryLog(id, e); err != nil {
+		panic(err)
+	}
+}
+
+func (l multiEventLogger) TryLog(id EventID, e Event) error {
+	return l.logEntry(NewEntry(id, e), e)
+}
+
+func (l multiEventLogger) logEntry(entry Entry, e Event) error {
+	var errs []error
+	for _, dst := range l {
+		if err := logEntry(dst, entry, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l multiEventLogger) Flush() error {
+	var errs []error
+	for _, dst := range l {
+		if err := flush(dst); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l multiEventLogger) Close() error {
+	var errs []error
+	for _, dst := range l {
+		if err := closeOrFlush(dst); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
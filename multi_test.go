@@ -0,0 +1,109 @@
+package lunk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMultiEventLogger(t *testing.T) {
+	buf1, buf2 := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	fake := fakeLogger{}
+	l := NewMultiEventLogger(NewJSONEventLogger(buf1), NewJSONEventLogger(buf2), &fake)
+
+	id := NewRootEventID()
+	l.Log(id, mockEvent{Example: "whee"})
+
+	var e1, e2 Entry
+	if err := json.Unmarshal(buf1.Bytes(), &e1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(buf2.Bytes(), &e2); err != nil {
+		t.Fatal(err)
+	}
+
+	if e1.EventID != id || e1.Properties["example"] != "whee" {
+		t.Errorf("Unexpected entry: %+v", e1)
+	}
+
+	if !e1.Time.Equal(e2.Time) {
+		t.Errorf("Mismatched timestamps: %v and %v", e1.Time, e2.Time)
+	}
+
+	if len(fake.events) != 1 || fake.events[0].id != id || fake.events[0].e != (mockEvent{Example: "whee"}) {
+		t.Errorf("Unexpected events: %+v", fake.events)
+	}
+}
+
+func TestMultiEventLoggerWrapped(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	fake := &fakeLogger{}
+	al := NewAsyncEventLogger(NewMultiEventLogger(NewJSONEventLogger(buf), fake), 10)
+
+	id := NewRootEventID()
+	al.Log(id, mockEvent{Example: "whee"})
+	al.Close()
+
+	var e Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.EventID != id || e.Properties["example"] != "whee" {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+
+	if len(fake.events) != 1 || fake.events[0].id != id || fake.events[0].e != (mockEvent{Example: "whee"}) {
+		t.Errorf("Unexpected events: %+v", fake.events)
+	}
+}
+
+func TestMultiEventLoggerFailures(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewMultiEventLogger(
+		panickingLogger{},
+		NewTextEventLogger(&failingWriter{failures: 1}),
+		NewJSONEventLogger(buf),
+	)
+
+	err := l.(FallibleEventLogger).TryLog(NewRootEventID(), mockEvent{})
+	if err == nil {
+		t.Fatal("Expected an error but none was returned")
+	}
+
+	if !errors.Is(err, errWriteFailed) || err.Error() != "woo\nwrite failed" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if buf.Len() == 0 {
+		t.Errorf("Entry wasn't logged to the JSON logger")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected a panic but none happened")
+		}
+	}()
+	l.Log(NewRootEventID(), mockEvent{})
+}
+
+func TestMultiEventLoggerClose(t *testing.T) {
+	w1, w2 := &closingWriter{}, &closingWriter{}
+	l := NewMultiEventLogger(NewTextEventLogger(bufio.NewWriter(w1)), NewJSONEventLogger(w2))
+	l.Log(NewRootEventID(), mockEvent{})
+
+	if err := l.(Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w1.writes != 1 {
+		t.Errorf("Buffered writer wasn't flushed")
+	}
+
+	if !w2.closed {
+		t.Errorf("Writer wasn't closed")
+	}
+}
@@ -0,0 +1,300 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+// A SyntaxError is returned when a query cannot be parsed.
+type SyntaxError struct {
+	Column int    // Column is the byte offset of the error, starting at 1.
+	Msg    string // Msg is a description of the error.
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokOp
+	tokWord
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	s    string // the token's text, unquoted if it's a string
+	pos  int
+}
+
+// operators are the comparison operators, with longer operators first.
+var operators = []string{"=~", "!~", "!=", "<=", ">=", "=", "<", ">"}
+
+// lex splits the given query into tokens.
+func lex(s string) ([]token, error) {
+	var toks []token
+
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+
+		if i == len(s) {
+			return append(toks, token{kind: tokEOF, pos: i}), nil
+		}
+
+		switch c := s[i]; {
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, s: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, s: ")", pos: i})
+			i++
+		case c == '"':
+			q, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, &SyntaxError{Column: i + 1, Msg: "bad quoted string"}
+			}
+			v, _ := strconv.Unquote(q)
+			toks = append(toks, token{kind: tokString, s: v, pos: i})
+			i += len(q)
+		case strings.IndexByte("=!<>~", c) >= 0:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Column: i + 1, Msg: fmt.Sprintf("unknown operator %q", c)}
+			}
+			toks = append(toks, token{kind: tokOp, s: op, pos: i})
+			i += len(op)
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t()\"=!<>~", s[j]) < 0 {
+				j++
+			}
+			toks = append(toks, token{kind: tokWord, s: s[i:j], pos: i})
+			i = j
+		}
+	}
+}
+
+type parser struct {
+	s    string
+	now  time.Time
+	toks []token
+}
+
+func (p *parser) parse() (matcher, error) {
+	toks, err := lex(p.s)
+	if err != nil {
+		return nil, err
+	}
+	p.toks = toks
+
+	m, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return m, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[0]
+}
+
+func (p *parser) next() token {
+	t := p.toks[0]
+	if t.kind != tokEOF {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokWord && strings.EqualFold(t.s, kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokEOF {
+		return &SyntaxError{Column: t.pos + 1, Msg: "unexpected end of query"}
+	}
+	return &SyntaxError{Column: t.pos + 1, Msg: fmt.Sprintf("unexpected %q", t.s)}
+}
+
+func (p *parser) or() (matcher, error) {
+	m, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	ms := or{m}
+	for p.keyword("or") {
+		m, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	return ms, nil
+}
+
+func (p *parser) and() (matcher, error) {
+	m, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	ms := and{m}
+	for p.keyword("and") {
+		m, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	return ms, nil
+}
+
+func (p *parser) unary() (matcher, error) {
+	if p.keyword("not") {
+		m, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{m: m}, nil
+	}
+
+	if p.peek().kind == tokLParen {
+		p.next()
+
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.unexpected(t)
+		}
+		return m, nil
+	}
+
+	return p.comparison()
+}
+
+var fields = map[string]bool{
+	"schema": true,
+	"host":   true,
+	"deploy": true,
+	"pid":    true,
+	"time":   true,
+	"root":   true,
+	"id":     true,
+	"parent": true,
+}
+
+func (p *parser) comparison() (matcher, error) {
+	f := p.next()
+	if f.kind != tokWord {
+		return nil, p.unexpected(f)
+	}
+
+	if !fields[f.s] && (!strings.HasPrefix(f.s, "p:") || f.s == "p:") {
+		return nil, &SyntaxError{Column: f.pos + 1, Msg: fmt.Sprintf("unknown field %q", f.s)}
+	}
+
+	op := p.next()
+	if op.kind != tokOp {
+		return nil, p.unexpected(op)
+	}
+
+	v := p.next()
+	if v.kind != tokWord && v.kind != tokString {
+		return nil, p.unexpected(v)
+	}
+
+	c := &comparison{
+		field: f.s,
+		op:    op.s,
+		s:     v.s,
+	}
+
+	bad := func(format string, args ...interface{}) error {
+		return &SyntaxError{Column: v.pos + 1, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case op.s == "=~" || op.s == "!~":
+		re, err := regexp.Compile(v.s)
+		if err != nil {
+			return nil, bad("bad regular expression: %v", err)
+		}
+		c.re = re
+	case f.s == "time":
+		t, err := p.parseTime(v.s)
+		if err != nil {
+			return nil, bad("bad time %q", v.s)
+		}
+		c.t = t
+	case f.s == "root" || f.s == "id" || f.s == "parent":
+		id, err := lunk.ParseID(v.s)
+		if err != nil {
+			return nil, bad("bad ID %q", v.s)
+		}
+		c.s = id.String()
+	case v.kind == tokWord:
+		if n, err := strconv.ParseFloat(v.s, 64); err == nil {
+			c.f, c.numeric = n, true
+		}
+	}
+
+	return c, nil
+}
+
+// parseTime parses a timestamp, "now", or a negative duration relative to now.
+func (p *parser) parseTime(s string) (time.Time, error) {
+	if s == "now" {
+		return p.now, nil
+	}
+
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return p.now.Add(d), nil
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,52 @@
+package query
+
+import "testing"
+
+func TestParseErrors(t *testing.T) {
+	for q, expected := range map[string]string{
+		``:                        `column 1: unexpected end of query`,
+		`schema`:                  `column 7: unexpected end of query`,
+		`schema =`:                `column 9: unexpected end of query`,
+		`schema = woo host = woo`: `column 14: unexpected "host"`,
+		`schema = = woo`:          `column 10: unexpected "="`,
+		`schema woo`:              `column 8: unexpected "woo"`,
+		`(schema = woo`:           `column 14: unexpected end of query`,
+		`schema = woo)`:           `column 13: unexpected ")"`,
+		`schema ~ woo`:            `column 8: unknown operator '~'`,
+		`schema = "woo`:           `column 10: bad quoted string`,
+		`woo = 1`:                 `column 1: unknown field "woo"`,
+		`p: = 1`:                  `column 1: unknown field "p:"`,
+		`p:url =~ "("`:            "column 10: bad regular expression: error parsing regexp: missing closing ): `(`",
+		`time > yesterday`:        `column 8: bad time "yesterday"`,
+		`root = woo`:              `column 8: bad ID "woo"`,
+		`schema = a and (`:        `column 17: unexpected end of query`,
+		`not`:                     `column 4: unexpected end of query`,
+	} {
+		_, err := Parse(q)
+		if err == nil {
+			t.Errorf("%s: expected an error but none was returned", q)
+			continue
+		}
+
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("%s: unexpected error type %T", q, err)
+		}
+
+		if s := err.Error(); s != expected {
+			t.Errorf("%s was %q, but expected %q", q, s, expected)
+		}
+	}
+}
+
+func TestParseQuotedFields(t *testing.T) {
+	q, err := Parse(`p:msg = "woo \"yay\""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := entry
+	e.Properties = map[string]string{"msg": `woo "yay"`}
+	if !q.Match(e) {
+		t.Error("Query didn't match")
+	}
+}
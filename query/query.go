@@ -0,0 +1,212 @@
+// Package query provides a small language for selecting entries.
+//
+// A query is a set of comparisons, combined with "and", "or", "not", and
+// parentheses:
+//
+//	schema = httprequest and p:status >= 500 and host = "web-3" and time > -1h
+//
+// Comparisons are between a field and a value. The fields are "schema",
+// "host", "deploy", "pid", "time", "root", "id", and "parent", plus the entry's
+// properties, which are prefixed with "p:" (e.g., "p:elapsed"). Values are
+// either bare words or double-quoted strings, which may contain escape
+// sequences.
+//
+// The operators are "=", "!=", "<", "<=", ">", ">=", "=~" (matches a regular
+// expression), and "!~" (doesn't match a regular expression). If a bare value is
+// a number, the field is compared numerically; otherwise, fields are compared
+// as strings. Quoted values are always compared as strings, and regular
+// expressions are always matched against the field as a string.
+//
+// Times are compared as times, and their values are either RFC 3339 timestamps,
+// "now", or negative durations relative to when the query was parsed (e.g.,
+// "-1h30m"). Root, event, and parent IDs are compared as IDs.
+//
+// A comparison with a property which the entry doesn't have, or with a parent
+// ID when the entry has no parent, is false.
+package query
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+// A Query selects entries.
+type Query struct {
+	s string
+	m matcher
+}
+
+// Parse parses the given query, resolving relative times against the current
+// time.
+func Parse(s string) (*Query, error) {
+	return ParseAt(s, time.Now())
+}
+
+// ParseAt parses the given query, resolving relative times against the given
+// time.
+func ParseAt(s string, now time.Time) (*Query, error) {
+	p := &parser{s: s, now: now}
+	m, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{s: s, m: m}, nil
+}
+
+// Match returns whether or not the query selects the given entry.
+func (q *Query) Match(e lunk.Entry) bool {
+	return q.m.match(e)
+}
+
+// String returns the query as it was parsed.
+func (q *Query) String() string {
+	return q.s
+}
+
+// NewFilteringEntryReader returns an EntryReader which returns only those
+// entries read from the given EntryReader which the query selects.
+func NewFilteringEntryReader(r lunk.EntryReader, q *Query) lunk.EntryReader {
+	return filteringEntryReader{r: r, q: q}
+}
+
+type filteringEntryReader struct {
+	r lunk.EntryReader
+	q *Query
+}
+
+func (r filteringEntryReader) Read() (lunk.Entry, error) {
+	for {
+		e, err := r.r.Read()
+		if err != nil || r.q.Match(e) {
+			return e, err
+		}
+	}
+}
+
+type matcher interface {
+	match(e lunk.Entry) bool
+}
+
+type and []matcher
+
+func (m and) match(e lunk.Entry) bool {
+	for _, c := range m {
+		if !c.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []matcher
+
+func (m or) match(e lunk.Entry) bool {
+	for _, c := range m {
+		if c.match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+type not struct {
+	m matcher
+}
+
+func (m not) match(e lunk.Entry) bool {
+	return !m.m.match(e)
+}
+
+// A comparison compares a field with a value.
+type comparison struct {
+	field   string
+	op      string
+	s       string         // the value, for string comparisons
+	f       float64        // the value, for numeric comparisons
+	numeric bool           // whether or not the value is a number
+	t       time.Time      // the value, for time comparisons
+	re      *regexp.Regexp // the value, for regular expressions
+}
+
+func (c *comparison) match(e lunk.Entry) bool {
+	if c.field == "time" && c.re == nil {
+		return compare(c.op, e.Time.Compare(c.t))
+	}
+
+	v, ok := c.value(e)
+	if !ok {
+		return false
+	}
+
+	switch c.op {
+	case "=~":
+		return c.re.MatchString(v)
+	case "!~":
+		return !c.re.MatchString(v)
+	}
+
+	if c.numeric {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			switch {
+			case f < c.f:
+				return compare(c.op, -1)
+			case f > c.f:
+				return compare(c.op, 1)
+			}
+			return compare(c.op, 0)
+		} else if c.op != "=" && c.op != "!=" {
+			// non-numeric values can't be ordered against numbers
+			return false
+		}
+	}
+	return compare(c.op, strings.Compare(v, c.s))
+}
+
+// value returns the string value of the comparison's field for the given
+// entry, and whether or not the entry has that field.
+func (c *comparison) value(e lunk.Entry) (string, bool) {
+	switch c.field {
+	case "schema":
+		return e.Schema, true
+	case "host":
+		return e.Host, true
+	case "deploy":
+		return e.Deploy, true
+	case "pid":
+		return strconv.Itoa(e.PID), true
+	case "time":
+		return e.Time.Format(time.RFC3339Nano), true
+	case "root":
+		return e.Root.String(), true
+	case "id":
+		return e.ID.String(), true
+	case "parent":
+		return e.Parent.String(), e.Parent != 0
+	}
+
+	v, ok := e.Properties[strings.TrimPrefix(c.field, "p:")]
+	return v, ok
+}
+
+// compare returns whether the result of a comparison satisfies the operator.
+func compare(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
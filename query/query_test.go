@@ -0,0 +1,132 @@
+package query
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+var (
+	now = time.Date(2014, 5, 20, 15, 0, 0, 0, time.UTC)
+
+	entry = lunk.Entry{
+		EventID: lunk.EventID{
+			Root: 100,
+			ID:   200,
+		},
+		Schema: "httprequest",
+		Time:   time.Date(2014, 5, 20, 14, 42, 38, 0, time.UTC),
+		Host:   "web-3",
+		Deploy: "r500",
+		PID:    600,
+		Properties: map[string]string{
+			"status":  "503",
+			"elapsed": "142.5",
+			"url":     "/api/photos?id=1",
+			"method":  "GET",
+		},
+	}
+)
+
+func TestMatch(t *testing.T) {
+	for q, expected := range map[string]bool{
+		`schema = httprequest`:                     true,
+		`schema = "httprequest"`:                   true,
+		`schema != httprequest`:                    false,
+		`host = "web-3" and deploy = r500`:         true,
+		`host = web-1 or host = web-3`:             true,
+		`host = web-1 or host = web-2`:             false,
+		`not host = web-1`:                         true,
+		`not (host = web-1 or host = web-3)`:       false,
+		`NOT host = web-3 OR schema = httprequest`: true,
+		`pid = 600`:                             true,
+		`pid > 599 and pid < 601`:               true,
+		`p:status >= 500`:                       true,
+		`p:status >= 504`:                       false,
+		`p:status = 503.0`:                      true,
+		`p:status = "503.0"`:                    false,
+		`p:status != 503`:                       false,
+		`p:elapsed > 100`:                       true,
+		`p:elapsed <= 142.5`:                    true,
+		`p:method > 100`:                        false,
+		`p:method != 100`:                       true,
+		`p:method < H`:                          true,
+		`p:url =~ "^/api/"`:                     true,
+		`p:url !~ "^/api/"`:                     false,
+		`p:missing = ""`:                        false,
+		`p:missing != woo`:                      false,
+		`not p:missing = woo`:                   true,
+		`time > -1h`:                            true,
+		`time > -10m`:                           false,
+		`time < now`:                            true,
+		`time = "2014-05-20T14:42:38Z"`:         true,
+		`time >= 2014-05-20T14:42:39Z`:          false,
+		`time =~ "^2014-05"`:                    true,
+		`root = 64 and id = 00000000000000C8`:   true,
+		`root < 65`:                             true,
+		`parent = 0000000000000000`:             false,
+		`parent != 0000000000000001`:            false,
+		`schema = a or schema = b and host = c`: false,
+		`schema = httprequest or schema = b and host = c`:   true,
+		`(schema = httprequest or schema = b) and host = c`: false,
+	} {
+		query, err := ParseAt(q, now)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+
+		if actual := query.Match(entry); actual != expected {
+			t.Errorf("%s was %v, but expected %v", q, actual, expected)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	q, err := Parse("schema = woo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := q.String(); s != "schema = woo" {
+		t.Errorf("Was %q, but expected %q", s, "schema = woo")
+	}
+}
+
+type sliceEntryReader []lunk.Entry
+
+func (r *sliceEntryReader) Read() (lunk.Entry, error) {
+	if len(*r) == 0 {
+		return lunk.Entry{}, io.EOF
+	}
+	e := (*r)[0]
+	*r = (*r)[1:]
+	return e, nil
+}
+
+func TestFilteringEntryReader(t *testing.T) {
+	other := entry
+	other.Host = "web-1"
+
+	q, err := Parse("host = web-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewFilteringEntryReader(&sliceEntryReader{other, entry, other}, q)
+
+	e, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Host != "web-3" {
+		t.Errorf("Was %q, but expected %q", e.Host, "web-3")
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Was %v, but expected %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,535 @@
+package lunk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An EntryReader reads entries, e.g. from a log file.
+type EntryReader interface {
+	// Read returns the next entry, or io.EOF if there are no more entries.
+	Read() (Entry, error)
+}
+
+// A ParseError is returned by EntryReaders when an entry cannot be parsed.
+type ParseError struct {
+	Line   int   // Line is the line on which the error occurred, starting at 1.
+	Column int   // Column is the byte offset within the line, starting at 1, or 0 if unknown.
+	Err    error // Err is the underlying error.
+}
+
+func (e *ParseError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// NewJSONEntryReader returns an EntryReader which reads entries in the format
+// written by NewJSONEventLogger: one JSON object per line.
+func NewJSONEntryReader(r io.Reader) EntryReader {
+	return &jsonEntryReader{lineReader{r: bufio.NewReader(r)}}
+}
+
+// NewTextEntryReader returns an EntryReader which reads entries in the format
+// written by NewTextEventLogger: one line of attr="value" formatted text per
+// entry. Attribute names containing spaces, quotes, or '=' are quoted.
+func NewTextEntryReader(r io.Reader) EntryReader {
+	return &textEntryReader{lineReader{r: bufio.NewReader(r)}}
+}
+
+// lineReader reads non-blank lines, keeping track of line numbers.
+type lineReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (r *lineReader) next() (string, error) {
+	for {
+		s, err := r.r.ReadString('\n')
+		if err != nil && (err != io.EOF || s == "") {
+			return "", err
+		}
+		r.line++
+
+		s = strings.TrimRight(s, "\r\n")
+		if strings.TrimSpace(s) != "" {
+			return s, nil
+		}
+	}
+}
+
+type jsonEntryReader struct {
+	lineReader
+}
+
+func (r *jsonEntryReader) Read() (Entry, error) {
+	s, err := r.next()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var e Entry
+	if err := json.Unmarshal([]byte(s), &e); err != nil {
+		var col int
+		switch err := err.(type) {
+		case *json.SyntaxError:
+			col = int(err.Offset)
+		case *json.UnmarshalTypeError:
+			col = int(err.Offset)
+		}
+		return Entry{}, &ParseError{Line: r.line, Column: col, Err: err}
+	}
+	return e, nil
+}
+
+type textEntryReader struct {
+	lineReader
+}
+
+var (
+	errMissingEquals  = errors.New("missing '='")
+	errMissingSpace   = errors.New("missing space between attributes")
+	errBadQuotedValue = errors.New("bad quoted value")
+	errBadQuotedKey   = errors.New("bad quoted attribute")
+)
+
+func (r *textEntryReader) Read() (Entry, error) {
+	s, err := r.next()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e, col, err := parseTextEntry(s)
+	if err != nil {
+		return Entry{}, &ParseError{Line: r.line, Column: col, Err: err}
+	}
+	return e, nil
+}
+
+// parseTextEntry parses a line of text as an entry, returning the entry or the
+// column at which an error occurred and the error.
+func parseTextEntry(s string) (Entry, int, error) {
+	e := Entry{
+		Properties: make(map[string]string),
+	}
+	seen := make(map[string]bool, 8)
+
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+
+		keyCol := i + 1
+		var key string
+		if s[i] == '"' {
+			// keys with spaces, quotes, or '=' are quoted
+			q, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return Entry{}, keyCol, errBadQuotedKey
+			}
+			key, _ = strconv.Unquote(q)
+			i += len(q)
+			if i == len(s) || s[i] != '=' {
+				return Entry{}, i + 1, errMissingEquals
+			}
+			i++
+		} else {
+			eq := strings.IndexByte(s[i:], '=')
+			if eq < 0 {
+				return Entry{}, keyCol, errMissingEquals
+			}
+			key = s[i : i+eq]
+			i += eq + 1
+		}
+
+		valCol := i + 1
+		q, err := strconv.QuotedPrefix(s[i:])
+		if err != nil || q[0] != '"' {
+			return Entry{}, valCol, errBadQuotedValue
+		}
+		v, err := strconv.Unquote(q)
+		if err != nil {
+			return Entry{}, valCol, errBadQuotedValue
+		}
+		i += len(q)
+
+		if i < len(s) && s[i] != ' ' {
+			return Entry{}, i + 1, errMissingSpace
+		}
+
+		if seen[key] {
+			return Entry{}, keyCol, fmt.Errorf("duplicate attribute %q", key)
+		}
+		seen[key] = true
+
+		if err := setTextAttribute(&e, key, v); err != nil {
+			if err == errUnknownAttribute {
+				return Entry{}, keyCol, fmt.Errorf("unknown attribute %q", key)
+			}
+			return Entry{}, valCol, err
+		}
+	}
+
+	for _, key := range []string{"time", "schema", "id", "root"} {
+		if !seen[key] {
+			return Entry{}, 0, fmt.Errorf("missing attribute %q", key)
+		}
+	}
+
+	return e, 0, nil
+}
+
+var errUnknownAttribute = errors.New("unknown attribute")
+
+func setTextAttribute(e *Entry, key, v string) error {
+	if strings.HasPrefix(key, "p:") {
+		e.Properties[key[2:]] = v
+		return nil
+	}
+
+	var err error
+	switch key {
+	case "time":
+		e.Time, err = time.Parse(time.RFC3339, v)
+	case "host":
+		e.Host = v
+	case "pid":
+		e.PID, err = strconv.Atoi(v)
+	case "deploy":
+		e.Deploy = v
+	case "schema":
+		e.Schema = v
+	case "id":
+		e.ID, err = ParseID(v)
+	case "root":
+		e.Root, err = ParseID(v)
+	case "parent":
+		e.Parent, err = ParseID(v)
+	default:
+		return errUnknownAttribute
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad %s value %q", key, v)
+	}
+	return nil
+}
+
+// NewNormalizedCSVEntryReader returns an EntryReader which reads entries in the
+// format written by NewNormalizedCSVEntryRecorder, with NormalizedEventHeaders
+// and NormalizedPropertyHeaders header rows. Properties must be in the same
+// order as their events, which is the order in which
+// NewNormalizedCSVEntryRecorder writes them.
+func NewNormalizedCSVEntryReader(events, props *csv.Reader) EntryReader {
+	return &nCSVEntryReader{events: events, props: props}
+}
+
+// NewDenormalizedCSVEntryReader returns an EntryReader which reads entries in
+// the format written by NewDenormalizedCSVEntryRecorder, with a
+// DenormalizedEventHeaders header row. Consecutive rows with the same root and
+// event IDs are joined into a single entry, and rows with empty property names
+// and values add no properties.
+func NewDenormalizedCSVEntryReader(r *csv.Reader) EntryReader {
+	return &dCSVEntryReader{r: r}
+}
+
+var (
+	errUnknownEvent = errors.New("property for unknown event")
+)
+
+type nCSVEntryReader struct {
+	events, props *csv.Reader
+	started       bool
+	prop          *csvProperty // the next property, if it's been read
+	ahead         []Entry      // events read while looking for the next property's event
+}
+
+type csvProperty struct {
+	root, id    ID
+	name, value string
+	line        int
+}
+
+// of returns whether or not the property belongs to the given entry.
+func (p *csvProperty) of(e Entry) bool {
+	return p.root == e.Root && p.id == e.ID
+}
+
+func (r *nCSVEntryReader) Read() (Entry, error) {
+	if !r.started {
+		if err := readCSVHeaders(r.events, NormalizedEventHeaders); err != nil {
+			return Entry{}, err
+		}
+
+		if err := readCSVHeaders(r.props, NormalizedPropertyHeaders); err != nil && err != io.EOF {
+			return Entry{}, err
+		}
+		r.started = true
+	}
+
+	if len(r.ahead) == 0 {
+		e, err := r.readEvent()
+		if err == io.EOF {
+			// make sure there are no properties left over
+			if err := r.readProperty(); err != nil {
+				return Entry{}, err
+			}
+
+			if r.prop != nil {
+				return Entry{}, &ParseError{Line: r.prop.line, Err: errUnknownEvent}
+			}
+			return Entry{}, io.EOF
+		} else if err != nil {
+			return Entry{}, err
+		}
+		r.ahead = append(r.ahead, e)
+	}
+
+	if err := r.readProperty(); err != nil {
+		return Entry{}, err
+	}
+
+	// Properties are written in event order, so a property which isn't for
+	// the next event must be for a later one. Read ahead until we find it,
+	// failing fast if there isn't one.
+	if r.prop != nil && len(r.ahead) == 1 && !r.prop.of(r.ahead[0]) {
+		if err := r.readAhead(); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	e := r.ahead[0]
+	r.ahead = r.ahead[1:]
+
+	for r.prop != nil && r.prop.of(e) {
+		e.Properties[r.prop.name] = r.prop.value
+		r.prop = nil
+
+		if err := r.readProperty(); err != nil {
+			return Entry{}, err
+		}
+	}
+	return e, nil
+}
+
+// readEvent reads and parses the next event.
+func (r *nCSVEntryReader) readEvent() (Entry, error) {
+	rec, err := r.events.Read()
+	if err != nil {
+		return Entry{}, err
+	}
+	return parseCSVEvent(r.events, rec)
+}
+
+// readAhead reads events until it finds the one the next property belongs to.
+func (r *nCSVEntryReader) readAhead() error {
+	for {
+		e, err := r.readEvent()
+		if err == io.EOF {
+			return &ParseError{Line: r.prop.line, Err: errUnknownEvent}
+		} else if err != nil {
+			return err
+		}
+
+		r.ahead = append(r.ahead, e)
+		if r.prop.of(e) {
+			return nil
+		}
+	}
+}
+
+// readProperty reads the next property, if it hasn't already been read.
+func (r *nCSVEntryReader) readProperty() error {
+	if r.prop != nil {
+		return nil
+	}
+
+	rec, err := r.props.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	root, err := parseCSVID(r.props, rec, 0)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseCSVID(r.props, rec, 1)
+	if err != nil {
+		return err
+	}
+
+	line, _ := r.props.FieldPos(0)
+	r.prop = &csvProperty{
+		root:  root,
+		id:    id,
+		name:  rec[3],
+		value: rec[4],
+		line:  line,
+	}
+	return nil
+}
+
+type dCSVEntryReader struct {
+	r       *csv.Reader
+	started bool
+	next    *Entry // the next entry, if its first row has been read
+}
+
+func (r *dCSVEntryReader) Read() (Entry, error) {
+	if !r.started {
+		if err := readCSVHeaders(r.r, DenormalizedEventHeaders); err != nil {
+			return Entry{}, err
+		}
+		r.started = true
+	}
+
+	if r.next == nil {
+		e, err := r.readRow()
+		if err != nil {
+			return Entry{}, err
+		}
+		r.next = &e
+	}
+
+	e := *r.next
+	r.next = nil
+
+	for {
+		row, err := r.readRow()
+		if err == io.EOF {
+			return e, nil
+		} else if err != nil {
+			return Entry{}, err
+		}
+
+		if row.Root != e.Root || row.ID != e.ID {
+			r.next = &row
+			return e, nil
+		}
+
+		for k, v := range row.Properties {
+			e.Properties[k] = v
+		}
+	}
+}
+
+// readRow reads a single row as an entry with a single property, or none if the
+// row's property name and value are empty.
+func (r *dCSVEntryReader) readRow() (Entry, error) {
+	rec, err := r.r.Read()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e, err := parseCSVEvent(r.r, rec)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if rec[8] != "" || rec[9] != "" {
+		e.Properties[rec[8]] = rec[9]
+	}
+	return e, nil
+}
+
+// readCSVHeaders reads a header row and checks that it has the expected
+// headers.
+func readCSVHeaders(r *csv.Reader, expected []string) error {
+	rec, err := r.Read()
+	if err != nil {
+		return err
+	}
+
+	line, _ := r.FieldPos(0)
+	if len(rec) != len(expected) {
+		return &ParseError{
+			Line: line,
+			Err:  fmt.Errorf("bad headers %q, expected %q", rec, expected),
+		}
+	}
+
+	for i, h := range expected {
+		if rec[i] != h {
+			line, col := r.FieldPos(i)
+			return &ParseError{
+				Line:   line,
+				Column: col,
+				Err:    fmt.Errorf("bad header %q, expected %q", rec[i], h),
+			}
+		}
+	}
+	return nil
+}
+
+// parseCSVEvent parses the root, id, parent, schema, time, host, pid, and
+// deploy fields of a record.
+func parseCSVEvent(r *csv.Reader, rec []string) (Entry, error) {
+	var (
+		e   Entry
+		err error
+	)
+
+	if e.Root, err = parseCSVID(r, rec, 0); err != nil {
+		return Entry{}, err
+	}
+
+	if e.ID, err = parseCSVID(r, rec, 1); err != nil {
+		return Entry{}, err
+	}
+
+	if e.Parent, err = parseCSVID(r, rec, 2); err != nil {
+		return Entry{}, err
+	}
+
+	e.Schema = rec[3]
+
+	if e.Time, err = time.Parse(time.RFC3339Nano, rec[4]); err != nil {
+		return Entry{}, csvFieldError(r, rec, 4)
+	}
+
+	e.Host = rec[5]
+
+	if e.PID, err = strconv.Atoi(rec[6]); err != nil {
+		return Entry{}, csvFieldError(r, rec, 6)
+	}
+
+	e.Deploy = rec[7]
+	e.Properties = make(map[string]string)
+
+	return e, nil
+}
+
+func parseCSVID(r *csv.Reader, rec []string, field int) (ID, error) {
+	id, err := ParseID(rec[field])
+	if err != nil {
+		return 0, csvFieldError(r, rec, field)
+	}
+	return id, nil
+}
+
+func csvFieldError(r *csv.Reader, rec []string, field int) error {
+	line, col := r.FieldPos(field)
+	return &ParseError{
+		Line:   line,
+		Column: col,
+		Err:    fmt.Errorf("bad value %q", rec[field]),
+	}
+}
@@ -0,0 +1,237 @@
+package lunk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEntries = []Entry{
+	{
+		EventID: EventID{
+			Root: ID(100),
+			ID:   ID(200),
+		},
+		Schema: "event",
+		Time:   time.Date(2014, 5, 20, 14, 42, 38, 0, time.UTC),
+		Host:   "example.com",
+		PID:    600,
+		Deploy: "r500",
+		Properties: map[string]string{
+			"k1": "v1",
+			"k2": "a \"quoted\"\nvalue",
+
+			// a flattened map key
+			"m.a=b c": "v3",
+		},
+	},
+	{
+		EventID: EventID{
+			Root:   ID(100),
+			ID:     ID(300),
+			Parent: ID(200),
+		},
+		Schema:     "other",
+		Time:       time.Date(2014, 5, 20, 14, 42, 39, 0, time.UTC),
+		Host:       "example.com",
+		PID:        600,
+		Properties: map[string]string{},
+	},
+}
+
+func TestJSONEntryReaderRoundTrip(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	testEntryReaderRoundTrip(t, buf, NewJSONEventLogger(buf), NewJSONEntryReader(buf))
+}
+
+func TestTextEntryReaderRoundTrip(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	testEntryReaderRoundTrip(t, buf, NewTextEventLogger(buf), NewTextEntryReader(buf))
+}
+
+func testEntryReaderRoundTrip(t *testing.T, buf *bytes.Buffer, l EventLogger, r EntryReader) {
+	rec := NewLoggingEntryRecorder(l)
+	for _, e := range testEntries {
+		if err := rec.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.WriteString("\n") // blank lines are skipped
+
+	actual := readAllEntries(t, r)
+	if !reflect.DeepEqual(actual, testEntries) {
+		t.Errorf("Was %#v but expected %#v", actual, testEntries)
+	}
+}
+
+func TestJSONEntryReaderNoTrailingNewline(t *testing.T) {
+	r := NewJSONEntryReader(strings.NewReader(`{"root":"0000000000000064","id":"00000000000000c8","schema":"event"}`))
+
+	actual := readAllEntries(t, r)
+	if len(actual) != 1 || actual[0].Root != 100 || actual[0].ID != 200 {
+		t.Errorf("Unexpected entries: %+v", actual)
+	}
+}
+
+func TestJSONEntryReaderErrors(t *testing.T) {
+	for s, expected := range map[string]string{
+		"{\"schema\":\"a\"}\n\n{\"schema\":\"b\"": "line 3, column 13: unexpected end of JSON input",
+		"{\"schema\":\"a\"}\n{\"schema\":\"b\",}": "line 2, column 15: invalid character '}' looking for beginning of object key string",
+		"{\"schema\":\"a\"}\n{\"pid\":\"woo\"}":   "line 2, column 12: json: cannot unmarshal string into Go struct field Entry.pid of type int",
+		"{\"root\":\"woo\"}":                      `line 1: "woo" is not a valid ID`,
+	} {
+		testEntryReaderError(t, NewJSONEntryReader(strings.NewReader(s)), expected)
+	}
+}
+
+func TestTextEntryReaderErrors(t *testing.T) {
+	valid := `time="2014-05-20T14:42:38Z" schema="event" id="00000000000000c8" root="0000000000000064"`
+	for s, expected := range map[string]string{
+		valid + "\n" + valid + ` p:woo`:      `line 2, column 90: missing '='`,
+		valid + ` p:woo=bar`:                 `line 1, column 96: bad quoted value`,
+		valid + ` p:woo="bar`:                `line 1, column 96: bad quoted value`,
+		valid + ` p:woo="bar"baz="1"`:        `line 1, column 101: missing space between attributes`,
+		valid + ` schema="other"`:            `line 1, column 90: duplicate attribute "schema"`,
+		valid + ` woo="1"`:                   `line 1, column 90: unknown attribute "woo"`,
+		valid + ` pid="woo"`:                 `line 1, column 94: bad pid value "woo"`,
+		`time="yesterday"`:                   `line 1, column 6: bad time value "yesterday"`,
+		`schema="event" id="1" root="2"`:     `line 1: missing attribute "time"`,
+		valid + ` parent="00000000000g0096"`: `line 1, column 97: bad parent value "00000000000g0096"`,
+		valid + ` "p:woo=1`:                  `line 1, column 90: bad quoted attribute`,
+		valid + ` "p:m.a=b""x"`:              `line 1, column 99: missing '='`,
+	} {
+		testEntryReaderError(t, NewTextEntryReader(strings.NewReader(s)), expected)
+	}
+}
+
+func testEntryReaderError(t *testing.T, r EntryReader, expected string) {
+	var err error
+	for err == nil {
+		_, err = r.Read()
+	}
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err.Error() != expected {
+		t.Errorf("Was `%s` but expected `%s`", err, expected)
+	}
+}
+
+func readAllEntries(t *testing.T, r EntryReader) []Entry {
+	var entries []Entry
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return entries
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestNormalizedCSVEntryReaderRoundTrip(t *testing.T) {
+	eB, pB := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	eW, pW := csv.NewWriter(eB), csv.NewWriter(pB)
+	eW.Write(NormalizedEventHeaders)
+	pW.Write(NormalizedPropertyHeaders)
+
+	rec := NewNormalizedCSVEntryRecorder(eW, pW)
+	for _, e := range testEntries {
+		if err := rec.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.(Flusher).Flush()
+
+	actual := readAllEntries(t, NewNormalizedCSVEntryReader(csv.NewReader(eB), csv.NewReader(pB)))
+	if !reflect.DeepEqual(actual, testEntries) {
+		t.Errorf("Was %#v but expected %#v", actual, testEntries)
+	}
+}
+
+func TestDenormalizedCSVEntryReaderRoundTrip(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := csv.NewWriter(buf)
+	w.Write(DenormalizedEventHeaders)
+
+	rec := NewDenormalizedCSVEntryRecorder(w)
+	for _, e := range testEntries {
+		if err := rec.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.(Flusher).Flush()
+
+	actual := readAllEntries(t, NewDenormalizedCSVEntryReader(csv.NewReader(buf)))
+	if !reflect.DeepEqual(actual, testEntries) {
+		t.Errorf("Was %#v but expected %#v", actual, testEntries)
+	}
+}
+
+func TestNormalizedCSVEntryReaderErrors(t *testing.T) {
+	eH := "root,id,parent,schema,time,host,pid,deploy\n"
+	pH := "root,id,parent,prop_name,prop_value\n"
+	ev := "0000000000000064,00000000000000c8,0000000000000000,event,2014-05-20T14:42:38Z,example.com,600,r500\n"
+
+	for _, test := range []struct {
+		events, props, expected string
+	}{
+		{"root,id,parent,schema,time,host,pid\n", pH, `line 1: bad headers ["root" "id" "parent" "schema" "time" "host" "pid"], expected ["root" "id" "parent" "schema" "time" "host" "pid" "deploy"]`},
+		{eH, "root,id,parent,name,value\n", `line 1, column 16: bad header "name", expected "prop_name"`},
+		{eH + ev + "woo,00000000000000c8,0000000000000000,event,2014-05-20T14:42:38Z,example.com,600,r500\n", pH, `line 3, column 1: bad value "woo"`},
+		{eH + "0000000000000064,00000000000000c8,0000000000000000,event,yesterday,example.com,600,r500\n", pH, `line 2, column 58: bad value "yesterday"`},
+		{eH + "0000000000000064,00000000000000c8,0000000000000000,event,2014-05-20T14:42:38Z,example.com,woo,r500\n", pH, `line 2, column 91: bad value "woo"`},
+		{eH + ev, pH + "0000000000000064,00000000000000ff,0000000000000000,k,v\n", `line 2: property for unknown event`},
+	} {
+		r := NewNormalizedCSVEntryReader(
+			csv.NewReader(strings.NewReader(test.events)),
+			csv.NewReader(strings.NewReader(test.props)),
+		)
+		testEntryReaderError(t, r, test.expected)
+	}
+}
+
+func TestNormalizedCSVEntryReaderStrayProperty(t *testing.T) {
+	events := "root,id,parent,schema,time,host,pid,deploy\n" +
+		"0000000000000064,00000000000000c8,0000000000000000,event,2014-05-20T14:42:38Z,example.com,600,r500\n" +
+		"0000000000000064,000000000000012c,00000000000000c8,event,2014-05-20T14:42:39Z,example.com,600,r500\n"
+	props := "root,id,parent,prop_name,prop_value\n" +
+		"0000000000000064,00000000000000ff,0000000000000000,k,v\n" +
+		"0000000000000064,000000000000012c,00000000000000c8,k,v\n"
+
+	r := NewNormalizedCSVEntryReader(
+		csv.NewReader(strings.NewReader(events)),
+		csv.NewReader(strings.NewReader(props)),
+	)
+
+	e, err := r.Read()
+	if err == nil {
+		t.Fatalf("Unexpected entry: %+v", e)
+	}
+
+	expected := "line 2: property for unknown event"
+	if err.Error() != expected {
+		t.Errorf("Was `%s` but expected `%s`", err, expected)
+	}
+}
+
+func TestDenormalizedCSVEntryReaderErrors(t *testing.T) {
+	for s, expected := range map[string]string{
+		"root,id,parent,schema,time,host,pid,deploy,name,value\n": `line 1, column 44: bad header "name", expected "prop_name"`,
+		"root,id,parent,schema,time,host,pid,deploy,prop_name,prop_value\n0000000000000064,00000000000000c8,woo,event,2014-05-20T14:42:38Z,example.com,600,r500,k,v\n": `line 2, column 35: bad value "woo"`,
+	} {
+		testEntryReaderError(t, NewDenormalizedCSVEntryReader(csv.NewReader(strings.NewReader(s))), expected)
+	}
+}
@@ -2,7 +2,6 @@ package lunk
 
 import (
 	"encoding/csv"
-	"sort"
 	"strconv"
 	"time"
 )
@@ -13,8 +12,28 @@ type EntryRecorder interface {
 	Record(Entry) error
 }
 
+// NewRecordingEventLogger returns an EventLogger which creates entries for
+// events and records them with the given EntryRecorder. The returned EventLogger
+// is a FallibleEventLogger; its Log method panics if the entry cannot be
+// recorded. It is also a Flusher and a Closer, which flush and close the
+// EntryRecorder.
+func NewRecordingEventLogger(r EntryRecorder) EventLogger {
+	return recordingEventLogger{r: r}
+}
+
+// NewLoggingEntryRecorder returns an EntryRecorder which replays entries into
+// the given EventLogger. The metadata of replayed entries (e.g., time, host) is
+// preserved by the EventLoggers provided by this package; other EventLoggers
+// receive an event with only the entry's ID, schema, and properties. The
+// returned EntryRecorder is a Flusher and a Closer, which flush and close the
+// EventLogger.
+func NewLoggingEntryRecorder(l EventLogger) EntryRecorder {
+	return loggingEntryRecorder{l: l}
+}
+
 // NewNormalizedCSVEntryRecorder returns an EntryRecorder which writes events to
-// one CSV file and properties to another.
+// one CSV file and properties to another. The returned EntryRecorder is a
+// Flusher and a Closer, both of which flush the CSV writers.
 func NewNormalizedCSVEntryRecorder(events, props *csv.Writer) EntryRecorder {
 	return nCSVRecorder{
 		events: events,
@@ -24,7 +43,9 @@ func NewNormalizedCSVEntryRecorder(events, props *csv.Writer) EntryRecorder {
 
 // NewDenormalizedCSVEntryRecorder returns an EntryRecorder which writes events
 // and their properties to a single CSV file, duplicating event data when
-// necessary.
+// necessary. Events without properties are written as a single row with empty
+// property names and values. The returned EntryRecorder is a Flusher and a
+// Closer, both of which flush the CSV writer.
 func NewDenormalizedCSVEntryRecorder(w *csv.Writer) EntryRecorder {
 	return dCSVRecorder{
 		w: w,
@@ -71,11 +92,67 @@ var (
 	}
 )
 
+type recordingEventLogger struct {
+	r EntryRecorder
+}
+
+func (l recordingEventLogger) Log(id EventID, e Event) {
+	if err := l.TryLog(id, e); err != nil {
+		panic(err)
+	}
+}
+
+func (l recordingEventLogger) TryLog(id EventID, e Event) error {
+	return l.r.Record(NewEntry(id, e))
+}
+
+func (l recordingEventLogger) logEntry(entry Entry, _ Event) error {
+	return l.r.Record(entry)
+}
+
+func (l recordingEventLogger) Flush() error {
+	return flush(l.r)
+}
+
+func (l recordingEventLogger) Close() error {
+	return closeOrFlush(l.r)
+}
+
+type loggingEntryRecorder struct {
+	l EventLogger
+}
+
+func (r loggingEntryRecorder) Record(e Entry) error {
+	return logEntry(r.l, e, entryEvent{e: e})
+}
+
+func (r loggingEntryRecorder) Flush() error {
+	return flush(r.l)
+}
+
+func (r loggingEntryRecorder) Close() error {
+	return closeOrFlush(r.l)
+}
+
 type nCSVRecorder struct {
 	events *csv.Writer
 	props  *csv.Writer
 }
 
+func (r nCSVRecorder) Flush() error {
+	r.events.Flush()
+	r.props.Flush()
+
+	if err := r.events.Error(); err != nil {
+		return err
+	}
+	return r.props.Error()
+}
+
+func (r nCSVRecorder) Close() error {
+	return r.Flush()
+}
+
 func (r nCSVRecorder) Record(e Entry) error {
 	root, id, parent := e.Root.String(), e.ID.String(), e.Parent.String()
 
@@ -92,13 +169,7 @@ func (r nCSVRecorder) Record(e Entry) error {
 		return err
 	}
 
-	keys := make([]string, 0, len(e.Properties))
-	for k := range e.Properties {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	for _, k := range keys {
+	for _, k := range sortedKeys(e.Properties) {
 		v := e.Properties[k]
 		if err := r.props.Write([]string{
 			root,
@@ -119,12 +190,37 @@ type dCSVRecorder struct {
 	w *csv.Writer
 }
 
+func (r dCSVRecorder) Flush() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+func (r dCSVRecorder) Close() error {
+	return r.Flush()
+}
+
 func (r dCSVRecorder) Record(e Entry) error {
 	root, id, parent := e.Root.String(), e.ID.String(), e.Parent.String()
 	time := e.Time.Format(time.RFC3339Nano)
 	pid := strconv.Itoa(e.PID)
 
-	for k, v := range e.Properties {
+	if len(e.Properties) == 0 {
+		return r.w.Write([]string{
+			root,
+			id,
+			parent,
+			e.Schema,
+			time,
+			e.Host,
+			pid,
+			e.Deploy,
+			"",
+			"",
+		})
+	}
+
+	for _, k := range sortedKeys(e.Properties) {
+		v := e.Properties[k]
 		if err := r.w.Write([]string{
 			root,
 			id,
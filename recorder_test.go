@@ -3,6 +3,8 @@ package lunk
 import (
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -183,3 +185,188 @@ func TestDenormalizedCSVEntryRecorder(t *testing.T) {
 		t.Errorf("Was %#v but expected %#v", actual, expected)
 	}
 }
+
+func TestDenormalizedCSVEntryRecorderNoProperties(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := csv.NewWriter(buf)
+	r := NewDenormalizedCSVEntryRecorder(w)
+
+	e := Entry{
+		EventID: EventID{
+			Root: ID(100),
+			ID:   ID(200),
+		},
+		Schema: "event",
+		Time:   time.Date(2014, 5, 20, 14, 42, 38, 0, time.UTC),
+		Host:   "example.com",
+		PID:    600,
+		Deploy: "r500",
+	}
+
+	if err := r.Record(e); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	actual := buf.String()
+	expected := "0000000000000064,00000000000000c8,0000000000000000,event,2014-05-20T14:42:38Z,example.com,600,r500,,\n"
+	if actual != expected {
+		t.Errorf("Was %#v but expected %#v", actual, expected)
+	}
+}
+
+func TestCSVEntryRecorderFlush(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	r := NewDenormalizedCSVEntryRecorder(csv.NewWriter(buf))
+
+	e := Entry{
+		EventID: EventID{
+			Root: ID(100),
+			ID:   ID(200),
+		},
+		Schema: "event",
+		Properties: map[string]string{
+			"k1": "v1",
+		},
+	}
+
+	if err := r.Record(e); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("Unexpectedly unbuffered write: %s", buf.String())
+	}
+
+	if err := r.(Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() == 0 {
+		t.Errorf("Unexpectedly empty buffer")
+	}
+}
+
+func TestRecordingEventLogger(t *testing.T) {
+	r := &fakeRecorder{}
+	l := NewRecordingEventLogger(r)
+
+	id := NewRootEventID()
+	l.Log(id, mockEvent{Example: "whee"})
+
+	if len(r.entries) != 1 {
+		t.Fatalf("Unexpected entries: %+v", r.entries)
+	}
+
+	e := r.entries[0]
+	if e.EventID != id || e.Schema != "example" || e.Properties["example"] != "whee" {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+}
+
+func TestRecordingEventLoggerError(t *testing.T) {
+	r := &fakeRecorder{err: errors.New("woo")}
+	l := NewRecordingEventLogger(r)
+
+	if err := l.(FallibleEventLogger).TryLog(NewRootEventID(), mockEvent{}); err != r.err {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestRecordingEventLoggerCSV(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewRecordingEventLogger(NewDenormalizedCSVEntryRecorder(csv.NewWriter(buf)))
+	l.Log(NewRootEventID(), mockEvent{Example: "whee"})
+
+	if err := l.(Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0][3] != "example" || rows[0][9] != "whee" {
+		t.Errorf("Unexpected rows: %+v", rows)
+	}
+}
+
+func TestLoggingEntryRecorder(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	r := NewLoggingEntryRecorder(NewJSONEventLogger(buf))
+
+	expected := Entry{
+		EventID: EventID{
+			Root:   ID(100),
+			ID:     ID(200),
+			Parent: ID(150),
+		},
+		Schema: "event",
+		Time:   time.Date(2014, 5, 20, 14, 42, 38, 0, time.UTC),
+		Host:   "example.com",
+		PID:    600,
+		Deploy: "r500",
+		Properties: map[string]string{
+			"k1": "v1",
+			"k2": "v2",
+		},
+	}
+
+	if err := r.Record(expected); err != nil {
+		t.Fatal(err)
+	}
+
+	var actual Entry
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Was %#v but expected %#v", actual, expected)
+	}
+}
+
+func TestLoggingEntryRecorderOtherLoggers(t *testing.T) {
+	l := fakeLogger{}
+	r := NewLoggingEntryRecorder(&l)
+
+	id := NewRootEventID()
+	if err := r.Record(Entry{
+		EventID:    id,
+		Schema:     "event",
+		Properties: map[string]string{"k1": "v1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.events) != 1 || l.events[0].id != id {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	e := NewEntry(id, l.events[0].e)
+	if e.Schema != "event" || !reflect.DeepEqual(e.Properties, map[string]string{"k1": "v1"}) {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+}
+
+func TestLoggingEntryRecorderPanics(t *testing.T) {
+	r := NewLoggingEntryRecorder(panickingLogger{})
+
+	if err := r.Record(Entry{Schema: "event"}); err == nil || err.Error() != "woo" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+type fakeRecorder struct {
+	entries []Entry
+	err     error
+}
+
+func (r *fakeRecorder) Record(e Entry) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.entries = append(r.entries, e)
+	return nil
+}
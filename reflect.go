@@ -9,8 +9,17 @@ import (
 	"time"
 )
 
+// A flattener is a type which flattens itself, rather than being flattened via
+// reflection.
+type flattener interface {
+	flatten(prefix string, f func(k, v string))
+}
+
 func flattenValue(prefix string, v reflect.Value, f func(k, v string)) {
 	switch o := v.Interface().(type) {
+	case flattener:
+		o.flatten(prefix, f)
+		return
 	case time.Time:
 		f(prefix, o.Format(time.RFC3339Nano))
 		return
@@ -0,0 +1,80 @@
+package lunk
+
+import (
+	"reflect"
+	"time"
+)
+
+// A Span records the start and finish times of an event. When the span is
+// finished, its event is logged with three additional properties: "start" and
+// "end" (timestamps), and "elapsed" (fractional milliseconds).
+type Span struct {
+	l     EventLogger
+	id    EventID
+	start time.Time
+	e     Event
+}
+
+// StartSpan starts a new span for an event which is the child of the given
+// parent ID.
+func StartSpan(l EventLogger, parent EventID) *Span {
+	return &Span{
+		l:     l,
+		id:    NewEventID(parent),
+		start: time.Now(),
+	}
+}
+
+// StartSpan starts a new span for an event which is the child of this span's
+// event.
+func (s *Span) StartSpan() *Span {
+	return StartSpan(s.l, s.id)
+}
+
+// ID returns the ID of the span's event.
+func (s *Span) ID() EventID {
+	return s.id
+}
+
+// SetEvent sets the event which will be logged when the span is finished. If
+// no event is set, an event with the schema "span" and no properties other than
+// the span's timings is logged.
+func (s *Span) SetEvent(e Event) {
+	s.e = e
+}
+
+// Finish records the span's end time and logs its event.
+func (s *Span) Finish() {
+	end := time.Now()
+	s.l.Log(s.id, spanEvent{
+		Event:   s.e,
+		Start:   s.start,
+		End:     end,
+		Elapsed: end.Sub(s.start),
+	})
+}
+
+// spanEvent is an event with timings. Its properties are those of the wrapped
+// event, if any, plus the timings.
+type spanEvent struct {
+	Event
+	Start   time.Time
+	End     time.Time
+	Elapsed time.Duration
+}
+
+func (e spanEvent) Schema() string {
+	if e.Event == nil {
+		return "span"
+	}
+	return e.Event.Schema()
+}
+
+func (e spanEvent) flatten(prefix string, f func(k, v string)) {
+	if e.Event != nil {
+		flattenValue(prefix, reflect.ValueOf(e.Event), f)
+	}
+	flattenValue(nest(prefix, "start"), reflect.ValueOf(e.Start), f)
+	flattenValue(nest(prefix, "end"), reflect.ValueOf(e.End), f)
+	flattenValue(nest(prefix, "elapsed"), reflect.ValueOf(e.Elapsed), f)
+}
@@ -0,0 +1,101 @@
+package lunk
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSpan(t *testing.T) {
+	l := fakeLogger{}
+	parent := NewRootEventID()
+
+	s := StartSpan(&l, parent)
+	s.SetEvent(mockEvent{Example: "whee"})
+	time.Sleep(2 * time.Millisecond)
+	s.Finish()
+
+	if len(l.events) != 1 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	id := l.events[0].id
+	if id != s.ID() {
+		t.Errorf("Was %+v, but expected %+v", id, s.ID())
+	}
+
+	if id.Root != parent.Root || id.Parent != parent.ID || id.ID == 0 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	e := NewEntry(id, l.events[0].e)
+	if e.Schema != "example" {
+		t.Errorf("Unexpected schema: %v", e.Schema)
+	}
+
+	if e.Properties["example"] != "whee" {
+		t.Errorf("Unexpected properties: %+v", e.Properties)
+	}
+
+	start, err := time.Parse(time.RFC3339Nano, e.Properties["start"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end, err := time.Parse(time.RFC3339Nano, e.Properties["end"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elapsed, err := strconv.ParseFloat(e.Properties["elapsed"], 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed < 2 {
+		t.Errorf("Unexpectedly short elapsed time: %v", elapsed)
+	}
+
+	if end.Before(start) {
+		t.Errorf("End %v was before start %v", end, start)
+	}
+}
+
+func TestSpanWithoutEvent(t *testing.T) {
+	l := fakeLogger{}
+
+	s := StartSpan(&l, NewRootEventID())
+	s.Finish()
+
+	if len(l.events) != 1 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	e := NewEntry(l.events[0].id, l.events[0].e)
+	if e.Schema != "span" {
+		t.Errorf("Unexpected schema: %v", e.Schema)
+	}
+
+	if len(e.Properties) != 3 {
+		t.Errorf("Unexpected properties: %+v", e.Properties)
+	}
+}
+
+func TestNestedSpans(t *testing.T) {
+	l := fakeLogger{}
+	parent := NewRootEventID()
+
+	s := StartSpan(&l, parent)
+	child := s.StartSpan()
+	child.Finish()
+	s.Finish()
+
+	if len(l.events) != 2 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	id := l.events[0].id
+	if id.Root != parent.Root || id.Parent != s.ID().ID || id != child.ID() {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
@@ -0,0 +1,341 @@
+// Package stats provides real-time statistics over streams of entries, such
+// as the rate of edge server requests or their 95th percentile latency.
+package stats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+const (
+	// SubWindows is the number of sub-windows into which an Aggregator's
+	// window is divided. The window slides forward one sub-window at a time,
+	// so statistics may include entries from up to one sub-window before the
+	// start of the window.
+	SubWindows = 10
+
+	// DefaultLatencyProperty is the property from which an Aggregator reads
+	// latencies if none is set. It is the property in which Spans and the web
+	// package's events record their elapsed times in fractional milliseconds.
+	DefaultLatencyProperty = "elapsed"
+)
+
+// A Key identifies a series of entries with the same schema and dimension
+// values.
+type Key struct {
+	// Schema is the schema of the entries.
+	Schema string
+
+	// Dimensions are the entries' values for the Aggregator's dimensions,
+	// formatted as space-separated name="value" pairs in the order in which
+	// the dimensions were given (e.g., `host="web-3" deploy="r500"`).
+	Dimensions string
+}
+
+func (k Key) String() string {
+	if k.Dimensions == "" {
+		return k.Schema
+	}
+	return k.Schema + " " + k.Dimensions
+}
+
+// Stats are the statistics for a series of entries over a window.
+//
+// Entries with a lunk.SampleRateProperty property, as logged by a
+// SamplingEventLogger with target rates, each count as 1/p entries, where p is
+// the property's value, so counts and rates estimate those of all entries
+// rather than just those which were sampled. Latencies aren't weighted.
+type Stats struct {
+	// Count is the number of entries.
+	Count float64
+
+	// Rate is the number of entries per second.
+	Rate float64
+
+	// Latency is the distribution of the entries' latencies, in
+	// milliseconds, with exemplars. Entries without latencies are counted,
+	// but aren't included.
+	Latency *Histogram
+
+	// Errors is the number of entries which were errors.
+	Errors float64
+
+	// ErrorExemplars are a sample of the entries which were errors, with
+	// their latencies, if any.
+	ErrorExemplars []Exemplar
+}
+
+// ErrorRate returns the fraction of entries which were errors.
+func (s Stats) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Errors / s.Count
+}
+
+// An Aggregator maintains sliding-window statistics over entries, keyed by
+// their schemas and configurable dimensions. It is both an EventLogger and an
+// EntryRecorder, so it can receive events directly or entries from other
+// sources.
+type Aggregator struct {
+	window  time.Duration
+	sub     time.Duration
+	dims    []string
+	latency string
+	isError func(e lunk.Entry) bool
+	now     func() time.Time
+	m       *sync.Mutex
+	series  map[Key]*series
+	rules   []*rule
+}
+
+// NewAggregator returns a new Aggregator with statistics over the given window,
+// keyed by schema and the given dimensions. Dimensions are entry fields
+// ("host", "deploy", or "pid") or properties, prefixed with "p:" (e.g.,
+// "p:status").
+func NewAggregator(window time.Duration, dims ...string) *Aggregator {
+	sub := window / SubWindows
+	if sub <= 0 {
+		sub = 1
+	}
+
+	return &Aggregator{
+		window:  window,
+		sub:     sub,
+		dims:    dims,
+		latency: DefaultLatencyProperty,
+		isError: IsError,
+		now:     time.Now,
+		m:       new(sync.Mutex),
+		series:  make(map[Key]*series),
+	}
+}
+
+// SetLatencyProperty sets the property from which latencies are read. Its
+// values should be in fractional milliseconds.
+func (a *Aggregator) SetLatencyProperty(name string) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.latency = name
+}
+
+// SetErrorFunc sets the function which determines whether or not an entry was
+// an error. By default, IsError is used.
+func (a *Aggregator) SetErrorFunc(f func(e lunk.Entry) bool) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.isError = f
+}
+
+// IsError returns whether or not the given entry was an error: whether it has a
+// non-empty "error" property, as the web package's HTTPClientEvents do on
+// failure, or a "status" property of 500 or greater, as HTTPRequestEvents do
+// for server errors.
+func IsError(e lunk.Entry) bool {
+	if e.Properties["error"] != "" {
+		return true
+	}
+
+	status, err := strconv.Atoi(e.Properties["status"])
+	return err == nil && status >= 500
+}
+
+// Log records an entry for the event.
+func (a *Aggregator) Log(id lunk.EventID, e lunk.Event) {
+	a.Record(lunk.NewEntry(id, e))
+}
+
+// Record adds the entry to the statistics for its key, as of the current time.
+func (a *Aggregator) Record(e lunk.Entry) error {
+	k := a.Key(e)
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	s, ok := a.series[k]
+	if !ok {
+		s = newSeries()
+		a.series[k] = s
+	}
+
+	latency, err := strconv.ParseFloat(e.Properties[a.latency], 64)
+	s.slot(a.now(), a.sub).record(e.EventID, weight(e), latency, err == nil, a.isError(e))
+	return nil
+}
+
+// weight returns the number of entries which the given entry represents: 1/p,
+// if it was sampled with probability p, or 1.
+func weight(e lunk.Entry) float64 {
+	p, err := strconv.ParseFloat(e.Properties[lunk.SampleRateProperty], 64)
+	if err != nil || p <= 0 || p > 1 {
+		return 1
+	}
+	return 1 / p
+}
+
+// Key returns the key for the given entry.
+func (a *Aggregator) Key(e lunk.Entry) Key {
+	vals := make([]string, len(a.dims))
+	for i, d := range a.dims {
+		vals[i] = fmt.Sprintf("%s=%s", d, strconv.Quote(dimension(e, d)))
+	}
+
+	return Key{
+		Schema:     e.Schema,
+		Dimensions: strings.Join(vals, " "),
+	}
+}
+
+// Stats returns the statistics for the given key, and whether or not any
+// entries with that key were recorded during the window.
+func (a *Aggregator) Stats(k Key) (Stats, bool) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	s, ok := a.series[k]
+	if !ok {
+		return Stats{}, false
+	}
+
+	stats := a.stats(s, a.now())
+	return stats, stats.Count > 0
+}
+
+// SchemaStats returns the statistics for all entries with the given schema,
+// regardless of their dimensions.
+func (a *Aggregator) SchemaStats(schema string) Stats {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	return a.schemaStats(schema, a.now())
+}
+
+// schemaStats returns the statistics for all entries with the given schema over
+// the window ending at the given time. a.m must be held.
+func (a *Aggregator) schemaStats(schema string, now time.Time) Stats {
+	total := Stats{Latency: NewHistogram()}
+	for k, s := range a.series {
+		if k.Schema == schema {
+			total.merge(a.stats(s, now))
+		}
+	}
+	total.Rate = total.Count / a.window.Seconds()
+	return total
+}
+
+// Snapshot returns the statistics for all of the keys with entries recorded
+// during the window. Keys without any are forgotten.
+func (a *Aggregator) Snapshot() map[Key]Stats {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := a.now()
+	snapshot := make(map[Key]Stats, len(a.series))
+	for k, s := range a.series {
+		stats := a.stats(s, now)
+		if stats.Count == 0 {
+			delete(a.series, k)
+			continue
+		}
+		snapshot[k] = stats
+	}
+	return snapshot
+}
+
+// stats returns the statistics for the series over the window ending at the
+// given time. a.m must be held.
+func (a *Aggregator) stats(s *series, now time.Time) Stats {
+	stats := Stats{Latency: NewHistogram()}
+	var errors reservoir
+	start := now.Add(-a.window).Truncate(a.sub)
+	for _, sl := range s.slots {
+		if !sl.start.IsZero() && !sl.start.Before(start) && !sl.start.After(now) {
+			stats.Count += sl.count
+			stats.Errors += sl.errors
+			stats.Latency.Merge(sl.latency)
+			errors.merge(&sl.errorExemplars)
+		}
+	}
+	stats.ErrorExemplars = errors.items
+	stats.Rate = stats.Count / a.window.Seconds()
+	return stats
+}
+
+func (s *Stats) merge(o Stats) {
+	s.Count += o.Count
+	s.Errors += o.Errors
+	s.Latency.Merge(o.Latency)
+	s.ErrorExemplars = append(s.ErrorExemplars, o.ErrorExemplars...)
+}
+
+func dimension(e lunk.Entry, d string) string {
+	switch d {
+	case "host":
+		return e.Host
+	case "deploy":
+		return e.Deploy
+	case "pid":
+		return strconv.Itoa(e.PID)
+	}
+	return e.Properties[strings.TrimPrefix(d, "p:")]
+}
+
+// A series is a ring of sub-windows.
+type series struct {
+	slots [SubWindows + 1]slot
+}
+
+func newSeries() *series {
+	s := &series{}
+	for i := range s.slots {
+		s.slots[i].latency = NewHistogram()
+	}
+	return s
+}
+
+// slot returns the sub-window for the given time, clearing it if it was last
+// used for an earlier sub-window.
+func (s *series) slot(t time.Time, sub time.Duration) *slot {
+	start := t.Truncate(sub)
+	i := start.UnixNano() / int64(sub) % int64(len(s.slots))
+	if i < 0 {
+		i += int64(len(s.slots))
+	}
+
+	sl := &s.slots[i]
+	if !sl.start.Equal(start) {
+		*sl = slot{
+			start:   start,
+			latency: NewHistogram(),
+		}
+	}
+	return sl
+}
+
+// A slot is a sub-window of a series.
+type slot struct {
+	start          time.Time
+	count          float64 // weighted
+	errors         float64 // weighted
+	latency        *Histogram
+	errorExemplars reservoir
+}
+
+func (sl *slot) record(id lunk.EventID, weight, latency float64, ok, isError bool) {
+	sl.count += weight
+	if ok {
+		sl.latency.RecordExemplar(latency, id)
+	}
+
+	if isError {
+		sl.errors += weight
+		sl.errorExemplars.add(Exemplar{ID: id, Value: latency})
+	}
+}
@@ -0,0 +1,182 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestAggregator(dims ...string) (*Aggregator, *clock) {
+	c := &clock{t: time.Date(2014, 5, 20, 14, 42, 0, 0, time.UTC)}
+	a := NewAggregator(10*time.Second, dims...)
+	a.now = c.now
+	return a, c
+}
+
+func entry(schema, host, elapsed string) lunk.Entry {
+	e := lunk.Entry{
+		Schema:     schema,
+		Host:       host,
+		Properties: map[string]string{},
+	}
+	if elapsed != "" {
+		e.Properties["elapsed"] = elapsed
+	}
+	return e
+}
+
+func TestAggregator(t *testing.T) {
+	a, _ := newTestAggregator("host")
+
+	a.Record(entry("httprequest", "web-1", "10"))
+	a.Record(entry("httprequest", "web-1", "20"))
+	a.Record(entry("httprequest", "web-2", "30"))
+	a.Record(entry("httprequest", "web-2", ""))
+	a.Record(entry("query", "web-1", "1"))
+
+	s, ok := a.Stats(Key{Schema: "httprequest", Dimensions: `host="web-1"`})
+	if !ok {
+		t.Fatal("No stats found")
+	}
+
+	if s.Count != 2 || s.Rate != 0.2 || s.Latency.Max() != 20 {
+		t.Errorf("Unexpected stats: %v/%v/%v", s.Count, s.Rate, s.Latency.Max())
+	}
+
+	s = a.SchemaStats("httprequest")
+	if s.Count != 4 || s.Latency.Count() != 3 || s.Latency.Mean() != 20 {
+		t.Errorf("Unexpected stats: %v/%d/%v", s.Count, s.Latency.Count(), s.Latency.Mean())
+	}
+
+	if len(a.Snapshot()) != 3 {
+		t.Errorf("Was %d keys, but expected %d", len(a.Snapshot()), 3)
+	}
+
+	if _, ok := a.Stats(Key{Schema: "woo"}); ok {
+		t.Error("Unexpected stats")
+	}
+}
+
+func TestAggregatorWindow(t *testing.T) {
+	a, c := newTestAggregator()
+	k := Key{Schema: "httprequest"}
+
+	a.Record(entry("httprequest", "", "10"))
+	c.t = c.t.Add(5 * time.Second)
+	a.Record(entry("httprequest", "", "20"))
+
+	if s, _ := a.Stats(k); s.Count != 2 {
+		t.Errorf("Was %v, but expected %v", s.Count, 2)
+	}
+
+	c.t = c.t.Add(6 * time.Second)
+	if s, _ := a.Stats(k); s.Count != 1 || s.Latency.Max() != 20 {
+		t.Errorf("Unexpected stats: %v/%v", s.Count, s.Latency.Max())
+	}
+
+	// the ring wraps around, reusing the first entry's sub-window
+	c.t = c.t.Add(20 * time.Second)
+	a.Record(entry("httprequest", "", "30"))
+	if s, _ := a.Stats(k); s.Count != 1 || s.Latency.Max() != 30 {
+		t.Errorf("Unexpected stats: %v/%v", s.Count, s.Latency.Max())
+	}
+
+	c.t = c.t.Add(time.Minute)
+	if len(a.Snapshot()) != 0 {
+		t.Errorf("Unexpected snapshot: %v", a.Snapshot())
+	}
+
+	if _, ok := a.Stats(k); ok {
+		t.Error("Unexpected stats")
+	}
+}
+
+func TestAggregatorKey(t *testing.T) {
+	a, _ := newTestAggregator("host", "deploy", "pid", "p:status")
+
+	e := entry("httprequest", "web-1", "")
+	e.Deploy = "r500"
+	e.PID = 600
+	e.Properties["status"] = "200"
+
+	k := a.Key(e)
+	expected := Key{Schema: "httprequest", Dimensions: `host="web-1" deploy="r500" pid="600" p:status="200"`}
+	if k != expected {
+		t.Errorf("Was %#v, but expected %#v", k, expected)
+	}
+
+	if s := k.String(); s != `httprequest host="web-1" deploy="r500" pid="600" p:status="200"` {
+		t.Errorf("Unexpected string: %q", s)
+	}
+}
+
+func TestAggregatorLatencyProperty(t *testing.T) {
+	a, _ := newTestAggregator()
+	a.SetLatencyProperty("duration")
+
+	e := entry("query", "", "10")
+	e.Properties["duration"] = "5"
+	a.Record(e)
+
+	if s := a.SchemaStats("query"); s.Latency.Max() != 5 {
+		t.Errorf("Was %v, but expected %v", s.Latency.Max(), 5)
+	}
+}
+
+func TestAggregatorLog(t *testing.T) {
+	a, _ := newTestAggregator()
+
+	var _ lunk.EventLogger = a
+	var _ lunk.EntryRecorder = a
+
+	a.Log(lunk.NewRootEventID(), lunk.Message("woo"))
+	if s := a.SchemaStats("message"); s.Count != 1 {
+		t.Errorf("Was %v, but expected %v", s.Count, 1)
+	}
+}
+
+func TestAggregatorSampleRates(t *testing.T) {
+	a, _ := newTestAggregator()
+
+	e := entry("httprequest", "web-1", "10")
+	e.Properties["sample_rate"] = "0.25"
+	a.Record(e)
+
+	e = entry("httprequest", "web-1", "20")
+	e.Properties["status"] = "500"
+	a.Record(e)
+
+	s := a.SchemaStats("httprequest")
+	if s.Count != 5 || s.Rate != 0.5 || s.Errors != 1 || s.ErrorRate() != 0.2 {
+		t.Errorf("Unexpected stats: %v/%v/%v", s.Count, s.Rate, s.Errors)
+	}
+
+	if s.Latency.Count() != 2 {
+		t.Errorf("Was %v, but expected %v", s.Latency.Count(), 2)
+	}
+}
+
+func TestAggregatorTargetRateSampling(t *testing.T) {
+	a, _ := newTestAggregator()
+	l := lunk.NewSamplingEventLogger(a)
+	l.SetSchemaTargetRate("message", 100)
+
+	for i := 0; i < 1000; i++ {
+		l.Log(lunk.NewRootEventID(), lunk.Message("woo"))
+	}
+
+	// roughly 330 entries are sampled, but they represent all 1000
+	s := a.SchemaStats("message")
+	if s.Count < 700 || s.Count > 1300 {
+		t.Errorf("Was %v, but expected roughly %v", s.Count, 1000)
+	}
+}
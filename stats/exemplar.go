@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/codahale/lunk"
+)
+
+const (
+	// ExemplarsPerBucket is the maximum number of exemplars a Histogram keeps
+	// for each of its exemplar buckets.
+	ExemplarsPerBucket = 4
+)
+
+// An Exemplar is an example of an event with a recorded value.
+type Exemplar struct {
+	// ID is the ID of the event.
+	ID lunk.EventID
+
+	// Value is the value recorded for the event.
+	Value float64
+}
+
+// A reservoir is a uniform sample of exemplars.
+type reservoir struct {
+	seen  uint64
+	items []Exemplar
+}
+
+func (r *reservoir) add(x Exemplar) {
+	r.seen++
+	if len(r.items) < ExemplarsPerBucket {
+		r.items = append(r.items, x)
+	} else if i := rand.Int63n(int64(r.seen)); i < ExemplarsPerBucket {
+		r.items[i] = x
+	}
+}
+
+// merge adds a sample of the other reservoir's exemplars to this one, weighting
+// each exemplar by the number of exemplars it represents.
+func (r *reservoir) merge(o *reservoir) {
+	if o.seen == 0 {
+		return
+	}
+
+	if len(r.items)+len(o.items) <= ExemplarsPerBucket {
+		r.seen += o.seen
+		r.items = append(r.items, o.items...)
+		return
+	}
+
+	// weighted reservoir sampling: keep the exemplars with the largest keys
+	type keyed struct {
+		x   Exemplar
+		key float64
+	}
+
+	var all []keyed
+	for _, s := range []*reservoir{r, o} {
+		w := float64(s.seen) / float64(len(s.items))
+		for _, x := range s.items {
+			all = append(all, keyed{x: x, key: math.Pow(rand.Float64(), 1/w)})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].key > all[j].key
+	})
+
+	items := make([]Exemplar, 0, ExemplarsPerBucket)
+	for _, k := range all[:ExemplarsPerBucket] {
+		items = append(items, k.x)
+	}
+	r.seen += o.seen
+	r.items = items
+}
+
+// exemplarBucket returns the index of the exemplar bucket for the given value.
+// Exemplar buckets are powers of two, so exemplars are kept for values of every
+// magnitude, and in particular for rare, slow events.
+func exemplarBucket(v float64) int {
+	if v <= 0 {
+		return math.MinInt32
+	}
+	return int(math.Floor(math.Log2(v)))
+}
@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+func exemplar(root lunk.ID, v float64) Exemplar {
+	return Exemplar{ID: lunk.EventID{Root: root, ID: root}, Value: v}
+}
+
+func TestReservoir(t *testing.T) {
+	var r reservoir
+	for i := 0; i < 100; i++ {
+		r.add(exemplar(lunk.ID(i), float64(i)))
+	}
+
+	if r.seen != 100 || len(r.items) != ExemplarsPerBucket {
+		t.Errorf("Unexpected reservoir: %d/%d", r.seen, len(r.items))
+	}
+}
+
+func TestReservoirMerge(t *testing.T) {
+	var a, b, c reservoir
+	a.add(exemplar(1, 1))
+	b.add(exemplar(2, 2))
+	a.merge(&b)
+	a.merge(&c)
+
+	if a.seen != 2 || len(a.items) != 2 {
+		t.Errorf("Unexpected reservoir: %d/%d", a.seen, len(a.items))
+	}
+
+	for i := 0; i < 10; i++ {
+		c.add(exemplar(3, 3))
+	}
+	a.merge(&c)
+
+	if a.seen != 12 || len(a.items) != ExemplarsPerBucket {
+		t.Errorf("Unexpected reservoir: %d/%d", a.seen, len(a.items))
+	}
+}
+
+func TestHistogramExemplars(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.RecordExemplar(float64(i), lunk.EventID{Root: lunk.ID(i)})
+	}
+	h.RecordExemplar(0, lunk.EventID{Root: 0})
+
+	// 0, 1, 2-3, and eight full buckets for 4-7 through 512-1000
+	all := h.Exemplars(0)
+	if expected := 1 + 1 + 2 + 8*ExemplarsPerBucket; len(all) != expected {
+		t.Errorf("Was %d exemplars, but expected %d", len(all), expected)
+	}
+
+	slow := h.Exemplars(512)
+	if len(slow) != ExemplarsPerBucket {
+		t.Fatalf("Was %d exemplars, but expected %d", len(slow), ExemplarsPerBucket)
+	}
+
+	for i, x := range slow {
+		if x.Value < 512 || lunk.ID(x.Value) != x.ID.Root {
+			t.Errorf("Unexpected exemplar: %#v", x)
+		}
+
+		if i > 0 && x.Value > slow[i-1].Value {
+			t.Errorf("Exemplars out of order: %#v", slow)
+		}
+	}
+
+	merged := NewHistogram()
+	merged.Merge(h)
+	merged.Merge(h)
+	if n := len(merged.Exemplars(512)); n != ExemplarsPerBucket {
+		t.Errorf("Was %d exemplars, but expected %d", n, ExemplarsPerBucket)
+	}
+}
@@ -0,0 +1,231 @@
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/codahale/lunk"
+)
+
+const (
+	// HistogramAccuracy is the relative accuracy of a Histogram's quantiles.
+	HistogramAccuracy = 0.01
+)
+
+var (
+	gamma    = (1 + HistogramAccuracy) / (1 - HistogramAccuracy)
+	logGamma = math.Log(gamma)
+)
+
+// A Histogram records the distribution of non-negative values, such as
+// latencies, in logarithmically-sized buckets. Its quantiles are accurate to
+// within HistogramAccuracy of the actual values, regardless of the values'
+// range, and histograms can be merged without any loss of accuracy.
+//
+// A Histogram also keeps a small, uniform sample of exemplars for each range of
+// values between consecutive powers of two, so that values can be traced back
+// to the events which produced them.
+//
+// A Histogram is not safe for concurrent use.
+type Histogram struct {
+	buckets   map[int]uint64
+	exemplars map[int]*reservoir
+	zeros     uint64
+	count     uint64
+	sum       float64
+	min, max  float64
+}
+
+// NewHistogram returns a new, empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{
+		buckets:   make(map[int]uint64),
+		exemplars: make(map[int]*reservoir),
+	}
+}
+
+// Record records the given value. Negative values are recorded as zero, and
+// values which aren't finite (NaN and infinities) are ignored.
+func (h *Histogram) Record(v float64) {
+	if !finite(v) {
+		return
+	}
+
+	if v < 0 {
+		v = 0
+	}
+
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if h.count == 0 || v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += v
+
+	if v == 0 {
+		h.zeros++
+		return
+	}
+	h.buckets[bucket(v)]++
+}
+
+// RecordExemplar records the given value, keeping the ID of the event which
+// produced it as a possible exemplar. As with Record, values which aren't
+// finite are ignored.
+func (h *Histogram) RecordExemplar(v float64, id lunk.EventID) {
+	if !finite(v) {
+		return
+	}
+
+	if v < 0 {
+		v = 0
+	}
+	h.Record(v)
+
+	b := exemplarBucket(v)
+	r, ok := h.exemplars[b]
+	if !ok {
+		r = new(reservoir)
+		h.exemplars[b] = r
+	}
+	r.add(Exemplar{ID: id, Value: v})
+}
+
+// Exemplars returns the exemplars with values greater than or equal to the given
+// value, largest first.
+func (h *Histogram) Exemplars(min float64) []Exemplar {
+	var exemplars []Exemplar
+	for _, r := range h.exemplars {
+		for _, x := range r.items {
+			if x.Value >= min {
+				exemplars = append(exemplars, x)
+			}
+		}
+	}
+
+	sort.Slice(exemplars, func(i, j int) bool {
+		return exemplars[i].Value > exemplars[j].Value
+	})
+	return exemplars
+}
+
+// Merge adds the values recorded by the given Histogram to this one.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.count == 0 {
+		return
+	}
+
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if h.count == 0 || o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.sum += o.sum
+	h.zeros += o.zeros
+
+	for i, n := range o.buckets {
+		h.buckets[i] += n
+	}
+
+	for b, or := range o.exemplars {
+		r, ok := h.exemplars[b]
+		if !ok {
+			r = new(reservoir)
+			h.exemplars[b] = r
+		}
+		r.merge(or)
+	}
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Sum returns the sum of the recorded values.
+func (h *Histogram) Sum() float64 {
+	return h.sum
+}
+
+// Min returns the smallest recorded value, or zero if none have been recorded.
+func (h *Histogram) Min() float64 {
+	return h.min
+}
+
+// Max returns the largest recorded value, or zero if none have been recorded.
+func (h *Histogram) Max() float64 {
+	return h.max
+}
+
+// Mean returns the mean of the recorded values, or zero if none have been
+// recorded.
+func (h *Histogram) Mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / float64(h.count)
+}
+
+// Quantile returns the value at the given quantile, which should be between 0.0
+// and 1.0, inclusive (e.g., 0.95 for the 95th percentile). If no values have
+// been recorded, it returns zero.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	switch {
+	case q <= 0:
+		return h.min
+	case q >= 1:
+		return h.max
+	}
+
+	// the rank of the value, starting at 0
+	rank := uint64(math.Round(q * float64(h.count-1)))
+	if rank < h.zeros {
+		return 0
+	}
+
+	n := h.zeros
+	for _, i := range h.sortedBuckets() {
+		n += h.buckets[i]
+		if n > rank {
+			return h.clamp(value(i))
+		}
+	}
+	return h.max
+}
+
+func (h *Histogram) sortedBuckets() []int {
+	idx := make([]int, 0, len(h.buckets))
+	for i := range h.buckets {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// clamp keeps a bucket's value within the range of recorded values.
+func (h *Histogram) clamp(v float64) float64 {
+	return math.Max(h.min, math.Min(h.max, v))
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// bucket returns the index of the bucket for the given positive value.
+func bucket(v float64) int {
+	return int(math.Ceil(math.Log(v) / logGamma))
+}
+
+// value returns the value which represents the bucket with the given index,
+// which is within HistogramAccuracy of all of the bucket's values.
+func value(i int) float64 {
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+
+	for _, v := range []float64{h.Quantile(0.5), h.Min(), h.Max(), h.Mean(), h.Sum()} {
+		if v != 0 {
+			t.Errorf("Was %v, but expected 0", v)
+		}
+	}
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	r := rand.New(rand.NewSource(100))
+	h := NewHistogram()
+
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = r.ExpFloat64() * 100
+		h.Record(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.01, 0.25, 0.5, 0.75, 0.95, 0.99, 0.999} {
+		expected := values[int(math.Round(q*float64(len(values)-1)))]
+		actual := h.Quantile(q)
+		if math.Abs(actual-expected)/expected > HistogramAccuracy {
+			t.Errorf("p%v was %v, but expected %v", q*100, actual, expected)
+		}
+	}
+
+	if h.Quantile(0) != values[0] || h.Quantile(1) != values[len(values)-1] {
+		t.Errorf("Unexpected range: %v-%v", h.Quantile(0), h.Quantile(1))
+	}
+
+	if h.Count() != 10000 {
+		t.Errorf("Was %v, but expected %v", h.Count(), 10000)
+	}
+}
+
+func TestHistogramZeros(t *testing.T) {
+	h := NewHistogram()
+	h.Record(0)
+	h.Record(-1)
+	h.Record(0)
+	h.Record(10)
+
+	if v := h.Quantile(0.5); v != 0 {
+		t.Errorf("Was %v, but expected %v", v, 0)
+	}
+
+	if v := h.Quantile(0.99); v != 10 {
+		t.Errorf("Was %v, but expected %v", v, 10)
+	}
+
+	if v := h.Mean(); v != 2.5 {
+		t.Errorf("Was %v, but expected %v", v, 2.5)
+	}
+}
+
+func TestHistogramNonFinite(t *testing.T) {
+	h := NewHistogram()
+	h.Record(10)
+	h.Record(math.Inf(1))
+	h.Record(math.Inf(-1))
+	h.Record(math.NaN())
+	h.RecordExemplar(math.Inf(1), lunk.EventID{Root: 1, ID: 1})
+	h.RecordExemplar(math.NaN(), lunk.EventID{Root: 1, ID: 2})
+	h.RecordExemplar(20, lunk.EventID{Root: 1, ID: 3})
+
+	if h.Count() != 2 || h.Max() != 20 || h.Sum() != 30 {
+		t.Errorf("Unexpected histogram: %d/%v/%v", h.Count(), h.Max(), h.Sum())
+	}
+
+	if v := h.Quantile(0.99); v < 20*(1-HistogramAccuracy) || v > 20 {
+		t.Errorf("Was %v, but expected %v", v, 20)
+	}
+
+	if x := h.Exemplars(0); len(x) != 1 || x[0].ID.ID != 3 {
+		t.Errorf("Unexpected exemplars: %+v", x)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 100; i++ {
+		all.Record(float64(i))
+		if i%2 == 0 {
+			a.Record(float64(i))
+		} else {
+			b.Record(float64(i))
+		}
+	}
+
+	a.Merge(b)
+	a.Merge(NewHistogram())
+
+	for _, q := range []float64{0, 0.5, 0.9, 1} {
+		if a.Quantile(q) != all.Quantile(q) {
+			t.Errorf("p%v was %v, but expected %v", q*100, a.Quantile(q), all.Quantile(q))
+		}
+	}
+
+	if a.Count() != 100 || a.Sum() != 5050 || a.Min() != 1 || a.Max() != 100 {
+		t.Errorf("Unexpected histogram: %d/%v/%v/%v", a.Count(), a.Sum(), a.Min(), a.Max())
+	}
+}
@@ -0,0 +1,171 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codahale/lunk"
+)
+
+// A Rule is a threshold on the statistics for a schema, across all of its
+// dimensions. A rule is breached when either of its thresholds is exceeded;
+// thresholds which are zero are ignored.
+type Rule struct {
+	// Schema is the schema of the entries to which the rule applies.
+	Schema string
+
+	// Quantile is the quantile of the latencies which is compared with the
+	// Latency threshold (e.g., 0.95 for the 95th percentile).
+	Quantile float64
+
+	// Latency is the threshold for the latency at Quantile, in milliseconds.
+	Latency float64
+
+	// ErrorRate is the threshold for the fraction of entries which were
+	// errors.
+	ErrorRate float64
+
+	// MinCount is the number of entries required within the window for the
+	// rule to be checked, to avoid alerts caused by a handful of entries.
+	MinCount uint64
+}
+
+func (r Rule) String() string {
+	var thresholds []string
+	if r.Latency > 0 {
+		thresholds = append(thresholds, fmt.Sprintf("p%g latency > %gms", r.Quantile*100, r.Latency))
+	}
+	if r.ErrorRate > 0 {
+		thresholds = append(thresholds, fmt.Sprintf("error rate > %g%%", r.ErrorRate*100))
+	}
+	return fmt.Sprintf("%s %s", r.Schema, strings.Join(thresholds, " or "))
+}
+
+// An Alert is a change in whether or not a Rule is breached.
+type Alert struct {
+	// Rule is the breached or resolved rule.
+	Rule Rule
+
+	// Resolved is whether or not the rule is no longer breached.
+	Resolved bool
+
+	// Stats are the statistics for the rule's schema.
+	Stats Stats
+
+	// Latency is the latency at the rule's quantile, in milliseconds.
+	Latency float64
+
+	// Roots are the root IDs of example trees which contributed to the
+	// breach: those with latencies over the rule's latency threshold, slowest
+	// first, followed by those with errors. Resolved alerts have none.
+	Roots []lunk.ID
+}
+
+func (a Alert) String() string {
+	if a.Resolved {
+		return fmt.Sprintf("resolved: %s", a.Rule)
+	}
+
+	s := fmt.Sprintf("breached: %s (p%g latency %.3fms, error rate %.2f%%)",
+		a.Rule, a.Rule.Quantile*100, a.Latency, a.Stats.ErrorRate()*100)
+	if len(a.Roots) > 0 {
+		roots := make([]string, len(a.Roots))
+		for i, id := range a.Roots {
+			roots[i] = id.String()
+		}
+		s += fmt.Sprintf(", mostly as a result of requests like those in trees %s",
+			strings.Join(roots, ", "))
+	}
+	return s
+}
+
+// An AlertHandler is called with alerts for a Rule.
+type AlertHandler func(a Alert)
+
+type rule struct {
+	Rule
+	h        AlertHandler
+	breached bool
+}
+
+// AddRule adds a rule, whose alerts are passed to the given AlertHandler. Rules
+// are checked by CheckRules.
+func (a *Aggregator) AddRule(r Rule, h AlertHandler) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.rules = append(a.rules, &rule{Rule: r, h: h})
+}
+
+// CheckRules checks all of the rules, passing an alert to a rule's
+// AlertHandler when it is first breached and when it is resolved. It should be
+// called periodically (e.g., once per sub-window), and returns the alerts.
+func (a *Aggregator) CheckRules() []Alert {
+	a.m.Lock()
+	var (
+		alerts   []Alert
+		handlers []AlertHandler
+	)
+	for _, r := range a.rules {
+		alert, breached := a.check(r.Rule)
+		if breached == r.breached {
+			continue
+		}
+
+		r.breached = breached
+		alert.Resolved = !breached
+		if alert.Resolved {
+			alert.Roots = nil
+		}
+		alerts = append(alerts, alert)
+		handlers = append(handlers, r.h)
+	}
+	a.m.Unlock()
+
+	// call the handlers without holding the lock, in case they record
+	// entries of their own
+	for i, h := range handlers {
+		if h != nil {
+			h(alerts[i])
+		}
+	}
+	return alerts
+}
+
+// check returns an alert for the given rule, and whether or not the rule is
+// breached. a.m must be held.
+func (a *Aggregator) check(r Rule) (Alert, bool) {
+	stats := a.schemaStats(r.Schema, a.now())
+	alert := Alert{
+		Rule:    r,
+		Stats:   stats,
+		Latency: stats.Latency.Quantile(r.Quantile),
+	}
+
+	if stats.Count == 0 || stats.Count < float64(r.MinCount) {
+		return alert, false
+	}
+
+	seen := make(map[lunk.ID]bool)
+	addRoots := func(exemplars []Exemplar) {
+		for _, x := range exemplars {
+			if !seen[x.ID.Root] {
+				seen[x.ID.Root] = true
+				alert.Roots = append(alert.Roots, x.ID.Root)
+			}
+		}
+	}
+
+	breached := false
+	if r.Latency > 0 && alert.Latency > r.Latency {
+		breached = true
+		addRoots(stats.Latency.Exemplars(r.Latency))
+	}
+
+	if r.ErrorRate > 0 && stats.ErrorRate() > r.ErrorRate {
+		breached = true
+		addRoots(stats.ErrorExemplars)
+	}
+
+	return alert, breached
+}
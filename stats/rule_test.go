@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+func request(root lunk.ID, elapsed, status int) lunk.Entry {
+	return lunk.Entry{
+		EventID: lunk.EventID{Root: root, ID: root},
+		Schema:  "httprequest",
+		Properties: map[string]string{
+			"elapsed": strconv.Itoa(elapsed),
+			"status":  strconv.Itoa(status),
+		},
+	}
+}
+
+func TestRuleLatency(t *testing.T) {
+	a, c := newTestAggregator("host")
+
+	var alerts []Alert
+	a.AddRule(Rule{Schema: "httprequest", Quantile: 0.95, Latency: 300, MinCount: 10}, func(a Alert) {
+		alerts = append(alerts, a)
+	})
+
+	for i := 1; i <= 18; i++ {
+		a.Record(request(lunk.ID(i), 100, 200))
+	}
+	a.Record(request(100, 500, 200))
+
+	if fired := a.CheckRules(); len(fired) != 0 {
+		t.Errorf("Unexpected alerts: %v", fired)
+	}
+
+	a.Record(request(101, 400, 200))
+	a.Record(request(102, 600, 200))
+
+	fired := a.CheckRules()
+	if len(fired) != 1 || !reflect.DeepEqual(fired, alerts) {
+		t.Fatalf("Unexpected alerts: %v / %v", fired, alerts)
+	}
+
+	alert := fired[0]
+	if alert.Resolved || alert.Latency < 500*(1-HistogramAccuracy) || alert.Stats.Count != 21 {
+		t.Errorf("Unexpected alert: %v", alert)
+	}
+
+	expected := []lunk.ID{102, 100, 101}
+	if !reflect.DeepEqual(alert.Roots, expected) {
+		t.Errorf("Was %v, but expected %v", alert.Roots, expected)
+	}
+
+	// still breached, so no new alert
+	if fired := a.CheckRules(); len(fired) != 0 {
+		t.Errorf("Unexpected alerts: %v", fired)
+	}
+
+	c.t = c.t.Add(time.Minute)
+	fired = a.CheckRules()
+	if len(fired) != 1 || !fired[0].Resolved || fired[0].Roots != nil {
+		t.Errorf("Unexpected alerts: %v", fired)
+	}
+
+	if len(alerts) != 2 {
+		t.Errorf("Was %d alerts, but expected %d", len(alerts), 2)
+	}
+}
+
+func TestRuleErrorRate(t *testing.T) {
+	a, _ := newTestAggregator()
+	a.AddRule(Rule{Schema: "httprequest", ErrorRate: 0.1}, nil)
+
+	for i := 1; i <= 8; i++ {
+		a.Record(request(lunk.ID(i), 10, 200))
+	}
+	a.Record(request(100, 10, 503))
+
+	clientErr := request(101, 0, 0)
+	clientErr.Properties = map[string]string{"error": "connection refused"}
+	a.Record(clientErr)
+
+	fired := a.CheckRules()
+	if len(fired) != 1 {
+		t.Fatalf("Unexpected alerts: %v", fired)
+	}
+
+	if r := fired[0].Stats.ErrorRate(); r != 0.2 {
+		t.Errorf("Was %v, but expected %v", r, 0.2)
+	}
+
+	roots := map[lunk.ID]bool{}
+	for _, id := range fired[0].Roots {
+		roots[id] = true
+	}
+
+	if !reflect.DeepEqual(roots, map[lunk.ID]bool{100: true, 101: true}) {
+		t.Errorf("Unexpected roots: %v", fired[0].Roots)
+	}
+}
+
+func TestRuleErrorFunc(t *testing.T) {
+	a, _ := newTestAggregator()
+	a.SetErrorFunc(func(e lunk.Entry) bool {
+		return e.Properties["status"] == "404"
+	})
+
+	a.Record(request(1, 10, 404))
+	a.Record(request(2, 10, 500))
+
+	if s := a.SchemaStats("httprequest"); s.Errors != 1 || s.ErrorExemplars[0].ID.Root != 1 {
+		t.Errorf("Unexpected stats: %v/%v", s.Errors, s.ErrorExemplars)
+	}
+}
+
+func TestAlertString(t *testing.T) {
+	r := Rule{Schema: "httprequest", Quantile: 0.95, Latency: 300, ErrorRate: 0.01}
+
+	alert := Alert{
+		Rule:    r,
+		Stats:   Stats{Count: 200, Errors: 1},
+		Latency: 342,
+		Roots:   []lunk.ID{100, 200},
+	}
+
+	expected := "breached: httprequest p95 latency > 300ms or error rate > 1% (p95 latency 342.000ms, error rate 0.50%), mostly as a result of requests like those in trees 0000000000000064, 00000000000000c8"
+	if s := alert.String(); s != expected {
+		t.Errorf("Was %q, but expected %q", s, expected)
+	}
+
+	alert = Alert{Rule: r, Resolved: true}
+	expected = "resolved: httprequest p95 latency > 300ms or error rate > 1%"
+	if s := alert.String(); s != expected {
+		t.Errorf("Was %q, but expected %q", s, expected)
+	}
+}
@@ -0,0 +1,262 @@
+package tree
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+// A Timing is the interval over which an event occurred, and how that time
+// was spent.
+type Timing struct {
+	// Node is the event's node.
+	Node *Node
+
+	// Start and End are the start and end times of the event.
+	Start, End time.Time
+
+	// Timed is whether or not the event's interval was recorded. Events
+	// without recorded intervals are treated as instantaneous.
+	Timed bool
+
+	// Elapsed is the time between the start and end of the event.
+	Elapsed time.Duration
+
+	// ChildTime is the portion of the event's elapsed time during which at
+	// least one of its children was occurring.
+	ChildTime time.Duration
+
+	// SelfTime is the portion of the event's elapsed time during which none
+	// of its children were occurring.
+	SelfTime time.Duration
+
+	// Concurrent is whether or not the event overlapped with any of its
+	// siblings.
+	Concurrent bool
+}
+
+// An Overlap is a pair of sibling events which occurred concurrently.
+type Overlap struct {
+	// A and B are the overlapping nodes. A started no later than B.
+	A, B *Node
+
+	// Duration is the time during which both events were occurring.
+	Duration time.Duration
+}
+
+// An Analysis describes how the time spent by the events in a tree was spent.
+type Analysis struct {
+	// Tree is the analyzed tree.
+	Tree *Tree
+
+	// Timings are the timings of all of the tree's events, by event ID.
+	Timings map[lunk.ID]*Timing
+
+	// CriticalPath is the chain of events which determined the elapsed time
+	// of the tree's first root, in depth-first order. Each event on the path
+	// is followed by the critical paths of those of its children which
+	// determined its end time, in order of their starts.
+	CriticalPath []*Node
+
+	// Overlaps are the pairs of sibling events which occurred concurrently,
+	// in depth-first order of their parents.
+	Overlaps []Overlap
+}
+
+// Analyze analyzes the timings of the given tree's events.
+//
+// An event's interval is determined by its "start", "end", and "elapsed"
+// properties (timestamps and fractional milliseconds, respectively), as
+// recorded by Spans and the web package's events. If an event has no "end"
+// property, its entry's time is used as its end time, since events are
+// generally logged when they end. Events without "start" or "elapsed"
+// properties are treated as instantaneous.
+//
+// Children's intervals are clipped to their parents' when calculating child and
+// self times, since events from different hosts are subject to clock skew.
+func Analyze(t *Tree) *Analysis {
+	a := &Analysis{
+		Tree:    t,
+		Timings: make(map[lunk.ID]*Timing, len(t.Nodes)),
+	}
+
+	t.Walk(func(n *Node, depth int) {
+		a.Timings[n.Entry.ID] = newTiming(n)
+	})
+
+	t.Walk(func(n *Node, depth int) {
+		a.analyzeChildren(n)
+	})
+
+	if len(t.Roots) > 0 {
+		a.CriticalPath = a.criticalPath(t.Roots[0], nil)
+	}
+
+	return a
+}
+
+// Interval returns the start and end times of the given entry's event, and
+// whether or not its interval was recorded, as described by Analyze.
+func Interval(e lunk.Entry) (start, end time.Time, ok bool) {
+	end = e.Time
+	if s, ok := e.Properties["end"]; ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			end = t
+		}
+	}
+
+	if s, ok := e.Properties["start"]; ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			if _, ok := e.Properties["end"]; !ok {
+				if elapsed, ok := elapsed(e); ok {
+					return t, t.Add(elapsed), true
+				}
+			}
+
+			if !t.After(end) {
+				return t, end, true
+			}
+		}
+	}
+
+	if elapsed, ok := elapsed(e); ok {
+		return end.Add(-elapsed), end, true
+	}
+
+	return end, end, false
+}
+
+func elapsed(e lunk.Entry) (time.Duration, bool) {
+	s, ok := e.Properties["elapsed"]
+	if !ok {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseFloat(s, 64)
+	if err != nil || ms < 0 {
+		return 0, false
+	}
+	return time.Duration(ms * float64(time.Millisecond)), true
+}
+
+func newTiming(n *Node) *Timing {
+	start, end, ok := Interval(n.Entry)
+	return &Timing{
+		Node:    n,
+		Start:   start,
+		End:     end,
+		Timed:   ok,
+		Elapsed: end.Sub(start),
+	}
+}
+
+// analyzeChildren calculates the node's child and self times, and finds its
+// overlapping children.
+func (a *Analysis) analyzeChildren(n *Node) {
+	parent := a.Timings[n.Entry.ID]
+	children := a.byStart(n.Children)
+
+	// sum the union of the children's intervals, clipped to the parent's
+	var (
+		child      time.Duration
+		start, end time.Time
+	)
+	for _, c := range children {
+		t := a.Timings[c.Entry.ID]
+		s, e := clip(t.Start, parent.Start, parent.End), clip(t.End, parent.Start, parent.End)
+		if s.After(end) {
+			child += end.Sub(start)
+			start, end = s, e
+		} else if e.After(end) {
+			end = e
+		}
+	}
+	child += end.Sub(start)
+
+	parent.ChildTime = child
+	parent.SelfTime = parent.Elapsed - child
+
+	for i, c := range children {
+		ct := a.Timings[c.Entry.ID]
+		for _, s := range children[i+1:] {
+			st := a.Timings[s.Entry.ID]
+			if !st.Start.Before(ct.End) {
+				break
+			}
+
+			end := ct.End
+			if st.End.Before(end) {
+				end = st.End
+			}
+
+			if d := end.Sub(st.Start); d > 0 {
+				ct.Concurrent, st.Concurrent = true, true
+				a.Overlaps = append(a.Overlaps, Overlap{A: c, B: s, Duration: d})
+			}
+		}
+	}
+}
+
+// criticalPath appends the critical path from the given node to the path. The
+// critical children are found by sweeping backwards from the node's end: the
+// child which ended last is critical, then the child which ended last before
+// that child started, and so on.
+func (a *Analysis) criticalPath(n *Node, path []*Node) []*Node {
+	path = append(path, n)
+
+	t := a.Timings[n.Entry.ID]
+	children := a.byEnd(n.Children)
+
+	var critical []*Node
+	cutoff := t.End
+	for _, c := range children {
+		ct := a.Timings[c.Entry.ID]
+		if !ct.Timed {
+			continue
+		}
+
+		// the last child may end after its parent due to clock skew, but
+		// the others must end before the cutoff
+		if ct.End.After(cutoff) && (len(critical) > 0 || !ct.Start.Before(cutoff)) {
+			continue
+		}
+
+		critical = append(critical, c)
+		cutoff = ct.Start
+	}
+
+	for i := len(critical) - 1; i >= 0; i-- {
+		path = a.criticalPath(critical[i], path)
+	}
+	return path
+}
+
+// byStart returns the given nodes ordered by their start times.
+func (a *Analysis) byStart(nodes []*Node) []*Node {
+	sorted := append([]*Node(nil), nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return a.Timings[sorted[i].Entry.ID].Start.Before(a.Timings[sorted[j].Entry.ID].Start)
+	})
+	return sorted
+}
+
+// byEnd returns the given nodes ordered by their end times, latest first.
+func (a *Analysis) byEnd(nodes []*Node) []*Node {
+	sorted := append([]*Node(nil), nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return a.Timings[sorted[i].Entry.ID].End.After(a.Timings[sorted[j].Entry.ID].End)
+	})
+	return sorted
+}
+
+func clip(t, min, max time.Time) time.Time {
+	if t.Before(min) {
+		return min
+	}
+	if t.After(max) {
+		return max
+	}
+	return t
+}
@@ -0,0 +1,112 @@
+package tree
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+func timed(id, parent lunk.ID, startMS, elapsedMS int) lunk.Entry {
+	e := entry(1, id, parent, startMS+elapsedMS)
+	e.Properties = map[string]string{
+		"elapsed": strconv.Itoa(elapsedMS),
+	}
+	return e
+}
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+func TestAnalyze(t *testing.T) {
+	a := Analyze(Build([]lunk.Entry{
+		timed(1, 0, 0, 100),
+		timed(2, 1, 5, 20),
+		timed(3, 1, 30, 65),
+		timed(4, 3, 35, 25),
+		timed(5, 3, 40, 50),
+		entry(1, 6, 1, 50),
+	})[0])
+
+	for id, expected := range map[lunk.ID][3]time.Duration{
+		1: {ms(100), ms(85), ms(15)},
+		2: {ms(20), 0, ms(20)},
+		3: {ms(65), ms(55), ms(10)},
+		6: {0, 0, 0},
+	} {
+		timing := a.Timings[id]
+		actual := [3]time.Duration{timing.Elapsed, timing.ChildTime, timing.SelfTime}
+		if actual != expected {
+			t.Errorf("%v was %v, but expected %v", id, actual, expected)
+		}
+	}
+
+	if s := a.Timings[4].Start; !s.Equal(start.Add(ms(35))) {
+		t.Errorf("Was %v, but expected %v", s, start.Add(ms(35)))
+	}
+
+	if a.Timings[6].Timed {
+		t.Error("Untimed event was timed")
+	}
+
+	testIDs(t, "CriticalPath", a.CriticalPath, 1, 2, 3, 5)
+
+	if len(a.Overlaps) != 1 {
+		t.Fatalf("Was %d overlaps, but expected 1", len(a.Overlaps))
+	}
+
+	o := a.Overlaps[0]
+	if o.A.Entry.ID != 4 || o.B.Entry.ID != 5 || o.Duration != ms(20) {
+		t.Errorf("Unexpected overlap: %v/%v/%v", o.A.Entry.ID, o.B.Entry.ID, o.Duration)
+	}
+
+	for id, expected := range map[lunk.ID]bool{2: false, 3: false, 4: true, 5: true} {
+		if c := a.Timings[id].Concurrent; c != expected {
+			t.Errorf("%v was %v, but expected %v", id, c, expected)
+		}
+	}
+}
+
+func TestAnalyzeClockSkew(t *testing.T) {
+	a := Analyze(Build([]lunk.Entry{
+		timed(1, 0, 0, 100),
+		timed(2, 1, 90, 20),
+	})[0])
+
+	if c := a.Timings[1].ChildTime; c != ms(10) {
+		t.Errorf("Was %v, but expected %v", c, ms(10))
+	}
+
+	testIDs(t, "CriticalPath", a.CriticalPath, 1, 2)
+}
+
+func TestInterval(t *testing.T) {
+	for _, test := range []struct {
+		props      map[string]string
+		start, end time.Duration
+		ok         bool
+	}{
+		{nil, 0, 0, false},
+		{map[string]string{"elapsed": "1.5"}, -1500 * time.Microsecond, 0, true},
+		{map[string]string{"elapsed": "woo"}, 0, 0, false},
+		{map[string]string{
+			"start": start.Add(-ms(10)).Format(time.RFC3339Nano),
+			"end":   start.Add(-ms(5)).Format(time.RFC3339Nano),
+		}, -ms(10), -ms(5), true},
+		{map[string]string{
+			"start":   start.Add(-ms(10)).Format(time.RFC3339Nano),
+			"elapsed": "20",
+		}, -ms(10), ms(10), true},
+	} {
+		e := entry(1, 1, 0, 0)
+		e.Properties = test.props
+
+		s, end, ok := Interval(e)
+		if !s.Equal(start.Add(test.start)) || !end.Equal(start.Add(test.end)) || ok != test.ok {
+			t.Errorf("%v was %v/%v/%v, but expected %v/%v/%v",
+				test.props, s, end, ok, start.Add(test.start), start.Add(test.end), test.ok)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package tree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A Graph renders trees as Graphviz DOT or Mermaid graphs, with a node per
+// event and an edge from each event to each of its children. Nodes are labeled
+// with their events' schemas, hosts, and elapsed times. Orphaned events, whose
+// parents are missing from the tree, are drawn with dashed borders.
+type Graph struct {
+	// CollapseSiblings is whether or not sibling events with the same schema
+	// are collapsed into a single node, labeled with the number of events,
+	// their hosts, and their total elapsed time. The children of collapsed
+	// events are collapsed in turn.
+	CollapseSiblings bool
+}
+
+// WriteDOT writes the given tree to the given writer as a Graphviz DOT graph,
+// without collapsing siblings.
+func WriteDOT(w io.Writer, t *Tree) error {
+	return Graph{}.WriteDOT(w, t)
+}
+
+// WriteMermaid writes the given tree to the given writer as a Mermaid
+// flowchart, without collapsing siblings.
+func WriteMermaid(w io.Writer, t *Tree) error {
+	return Graph{}.WriteMermaid(w, t)
+}
+
+// WriteDOT writes the given tree to the given writer as a Graphviz DOT graph.
+func (g Graph) WriteDOT(w io.Writer, t *Tree) error {
+	esc := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph \"%s\" {\n", t.ID)
+	fmt.Fprintln(bw, "  node [shape=box];")
+	g.walk(t, func(n *graphNode) {
+		style := ""
+		if n.orphan {
+			style = ", style=dashed"
+		}
+
+		lines := n.label()
+		for i, s := range lines {
+			lines[i] = esc.Replace(s)
+		}
+		fmt.Fprintf(bw, "  %s [label=\"%s\"%s];\n", n.id, strings.Join(lines, `\n`), style)
+
+		for _, c := range n.children {
+			fmt.Fprintf(bw, "  %s -> %s;\n", n.id, c.id)
+		}
+	})
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteMermaid writes the given tree to the given writer as a Mermaid
+// flowchart.
+func (g Graph) WriteMermaid(w io.Writer, t *Tree) error {
+	esc := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "graph TD")
+	fmt.Fprintln(bw, "  classDef orphan stroke-dasharray: 5 5;")
+	g.walk(t, func(n *graphNode) {
+		class := ""
+		if n.orphan {
+			class = ":::orphan"
+		}
+
+		lines := n.label()
+		for i, s := range lines {
+			lines[i] = esc.Replace(s)
+		}
+		fmt.Fprintf(bw, "  %s[\"%s\"]%s\n", n.id, strings.Join(lines, "<br/>"), class)
+
+		for _, c := range n.children {
+			fmt.Fprintf(bw, "  %s --> %s\n", n.id, c.id)
+		}
+	})
+	return bw.Flush()
+}
+
+// A graphNode is a node in a graph, which is either a single event or a set of
+// collapsed sibling events.
+type graphNode struct {
+	id       string
+	nodes    []*Node
+	orphan   bool
+	children []*graphNode
+}
+
+// walk calls the given function with each of the graph's nodes, in depth-first
+// order.
+func (g Graph) walk(t *Tree, f func(n *graphNode)) {
+	orphans := make(map[*Node]bool, len(t.Orphans))
+	for _, n := range t.Orphans {
+		orphans[n] = true
+	}
+
+	var visit func(n *graphNode)
+	visit = func(n *graphNode) {
+		f(n)
+		for _, c := range n.children {
+			visit(c)
+		}
+	}
+
+	// roots are never collapsed, since they don't share a parent
+	for _, r := range t.Roots {
+		n := g.build([]*Node{r})[0]
+		n.orphan = orphans[r]
+		visit(n)
+	}
+}
+
+// build returns the graph nodes for the given siblings.
+func (g Graph) build(siblings []*Node) []*graphNode {
+	var nodes []*graphNode
+	groups := make(map[string]*graphNode)
+	for _, s := range siblings {
+		if g.CollapseSiblings {
+			if n, ok := groups[s.Entry.Schema]; ok {
+				n.nodes = append(n.nodes, s)
+				continue
+			}
+		}
+
+		n := &graphNode{
+			id:    "e" + s.Entry.ID.String(),
+			nodes: []*Node{s},
+		}
+		groups[s.Entry.Schema] = n
+		nodes = append(nodes, n)
+	}
+
+	for _, n := range nodes {
+		var children []*Node
+		for _, m := range n.nodes {
+			children = append(children, m.Children...)
+		}
+		n.children = g.build(children)
+	}
+	return nodes
+}
+
+// label returns the lines of the node's label.
+func (n *graphNode) label() []string {
+	first := n.nodes[0].Entry
+	if len(n.nodes) == 1 {
+		lines := []string{first.Schema}
+		if first.Host != "" {
+			lines = append(lines, first.Host)
+		}
+		if start, end, ok := Interval(first); ok {
+			lines = append(lines, formatMS(end.Sub(start))+"ms")
+		}
+		return lines
+	}
+
+	lines := []string{fmt.Sprintf("%s ×%d", first.Schema, len(n.nodes))}
+
+	var (
+		hosts   []string
+		seen    = make(map[string]bool)
+		total   time.Duration
+		anyTime bool
+	)
+	for _, m := range n.nodes {
+		if h := m.Entry.Host; h != "" && !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+		if start, end, ok := Interval(m.Entry); ok {
+			total += end.Sub(start)
+			anyTime = true
+		}
+	}
+
+	switch {
+	case len(hosts) > 3:
+		lines = append(lines, fmt.Sprintf("%d hosts", len(hosts)))
+	case len(hosts) > 0:
+		lines = append(lines, strings.Join(hosts, ", "))
+	}
+
+	if anyTime {
+		lines = append(lines, "total "+formatMS(total)+"ms")
+	}
+	return lines
+}
@@ -0,0 +1,111 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+func graphTree() *Tree {
+	root := timed(1, 0, 0, 100)
+	root.Schema = "httprequest"
+	root.Host = "edge-1"
+
+	var entries []lunk.Entry
+	entries = append(entries, root)
+	for i, host := range []string{"db-1", "db-2", "db-1"} {
+		e := timed(lunk.ID(i+2), 1, 10*i, 10)
+		e.Schema = "query"
+		e.Host = host
+		entries = append(entries, e)
+	}
+
+	orphan := entry(1, 9, 8, 0)
+	orphan.Schema = `say "hi"`
+	entries = append(entries, orphan)
+
+	return Build(entries)[0]
+}
+
+func TestWriteDOT(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := WriteDOT(buf, graphTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph "0000000000000001" {
+  node [shape=box];
+  e0000000000000001 [label="httprequest\nedge-1\n100.000ms"];
+  e0000000000000001 -> e0000000000000002;
+  e0000000000000001 -> e0000000000000003;
+  e0000000000000001 -> e0000000000000004;
+  e0000000000000002 [label="query\ndb-1\n10.000ms"];
+  e0000000000000003 [label="query\ndb-2\n10.000ms"];
+  e0000000000000004 [label="query\ndb-1\n10.000ms"];
+  e0000000000000009 [label="say \"hi\"", style=dashed];
+}
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", s, expected)
+	}
+}
+
+func TestWriteDOTCollapsed(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := (Graph{CollapseSiblings: true}).WriteDOT(buf, graphTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph "0000000000000001" {
+  node [shape=box];
+  e0000000000000001 [label="httprequest\nedge-1\n100.000ms"];
+  e0000000000000001 -> e0000000000000002;
+  e0000000000000002 [label="query ×3\ndb-1, db-2\ntotal 30.000ms"];
+  e0000000000000009 [label="say \"hi\"", style=dashed];
+}
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", s, expected)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := WriteMermaid(buf, graphTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `graph TD
+  classDef orphan stroke-dasharray: 5 5;
+  e0000000000000001["httprequest<br/>edge-1<br/>100.000ms"]
+  e0000000000000001 --> e0000000000000002
+  e0000000000000001 --> e0000000000000003
+  e0000000000000001 --> e0000000000000004
+  e0000000000000002["query<br/>db-1<br/>10.000ms"]
+  e0000000000000003["query<br/>db-2<br/>10.000ms"]
+  e0000000000000004["query<br/>db-1<br/>10.000ms"]
+  e0000000000000009["say #quot;hi#quot;"]:::orphan
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", s, expected)
+	}
+}
+
+func TestWriteMermaidCollapsed(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := (Graph{CollapseSiblings: true}).WriteMermaid(buf, graphTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `graph TD
+  classDef orphan stroke-dasharray: 5 5;
+  e0000000000000001["httprequest<br/>edge-1<br/>100.000ms"]
+  e0000000000000001 --> e0000000000000002
+  e0000000000000002["query ×3<br/>db-1, db-2<br/>total 30.000ms"]
+  e0000000000000009["say #quot;hi#quot;"]:::orphan
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", s, expected)
+	}
+}
@@ -0,0 +1,337 @@
+// Package tree assembles entries into trees of events.
+//
+// All of the events which share a root ID form a partially-ordered tree, with
+// each event's parent ID linking it to its parent event. Because the events in
+// a tree are logged by many services, a tree assembled from logs may be
+// incomplete or inconsistent: some parents may never have been logged, some
+// events may have been logged more than once, and bad IDs may have introduced
+// cycles. Trees record each of these problems rather than failing.
+package tree
+
+import (
+	"io"
+	"sort"
+
+	"github.com/codahale/lunk"
+)
+
+// A Node is an event in a tree.
+type Node struct {
+	// Entry is the entry for the event.
+	Entry lunk.Entry
+
+	// Parent is the node's parent, or nil if the node is one of its tree's
+	// roots.
+	Parent *Node
+
+	// Children are the node's children, ordered by time.
+	Children []*Node
+}
+
+// Ancestors returns the node's ancestors, starting with its parent.
+func (n *Node) Ancestors() []*Node {
+	var nodes []*Node
+	for p := n.Parent; p != nil; p = p.Parent {
+		nodes = append(nodes, p)
+	}
+	return nodes
+}
+
+// Descendants returns the node's descendants, in depth-first order.
+func (n *Node) Descendants() []*Node {
+	var nodes []*Node
+	for _, c := range n.Children {
+		walk(c, 0, func(n *Node, depth int) {
+			nodes = append(nodes, n)
+		})
+	}
+	return nodes
+}
+
+// Depth returns the number of ancestors the node has.
+func (n *Node) Depth() int {
+	depth := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// A Tree is the set of events which share a root ID.
+type Tree struct {
+	// ID is the root ID shared by all of the tree's events.
+	ID lunk.ID
+
+	// Root is the root event, or nil if the root event is missing.
+	Root *Node
+
+	// Roots are the nodes without parents, ordered by time, with the root
+	// event first. A complete tree has a single root, the root event.
+	Roots []*Node
+
+	// Nodes are all of the tree's nodes, by event ID.
+	Nodes map[lunk.ID]*Node
+
+	// Orphans are the nodes whose parents are missing from the tree, ordered
+	// by time. They are included in Roots.
+	Orphans []*Node
+
+	// Duplicates are the entries whose event IDs had already been seen. Only
+	// the first entry for each event ID is included in the tree.
+	Duplicates []lunk.Entry
+
+	// Cycles are the sets of nodes whose parent IDs form cycles. Each cycle
+	// is broken by detaching its earliest node from its parent, which makes
+	// that node one of the tree's roots. Each cycle starts with the detached
+	// node, followed by its former ancestors.
+	Cycles [][]*Node
+}
+
+// Walk calls the given function with each of the tree's nodes and their depths,
+// in depth-first order.
+func (t *Tree) Walk(f func(n *Node, depth int)) {
+	for _, n := range t.Roots {
+		walk(n, 0, f)
+	}
+}
+
+func walk(n *Node, depth int, f func(n *Node, depth int)) {
+	f(n, depth)
+	for _, c := range n.Children {
+		walk(c, depth+1, f)
+	}
+}
+
+// DepthFirst returns the tree's nodes in depth-first order.
+func (t *Tree) DepthFirst() []*Node {
+	nodes := make([]*Node, 0, len(t.Nodes))
+	t.Walk(func(n *Node, depth int) {
+		nodes = append(nodes, n)
+	})
+	return nodes
+}
+
+// BreadthFirst returns the tree's nodes in breadth-first order.
+func (t *Tree) BreadthFirst() []*Node {
+	nodes := make([]*Node, 0, len(t.Nodes))
+	nodes = append(nodes, t.Roots...)
+	for i := 0; i < len(nodes); i++ {
+		nodes = append(nodes, nodes[i].Children...)
+	}
+	return nodes
+}
+
+// A Builder groups entries by root ID and assembles them into trees. It is an
+// EntryRecorder, so entries can be recorded with it directly.
+type Builder struct {
+	entries map[lunk.ID][]lunk.Entry
+	roots   []lunk.ID
+}
+
+// NewBuilder returns a new Builder.
+func NewBuilder() *Builder {
+	return &Builder{
+		entries: make(map[lunk.ID][]lunk.Entry),
+	}
+}
+
+// Add adds the given entry.
+func (b *Builder) Add(e lunk.Entry) {
+	if _, ok := b.entries[e.Root]; !ok {
+		b.roots = append(b.roots, e.Root)
+	}
+	b.entries[e.Root] = append(b.entries[e.Root], e)
+}
+
+// Record adds the given entry.
+func (b *Builder) Record(e lunk.Entry) error {
+	b.Add(e)
+	return nil
+}
+
+// Tree returns the tree with the given root ID, or nil if no entries with that
+// root ID have been added.
+func (b *Builder) Tree(root lunk.ID) *Tree {
+	entries, ok := b.entries[root]
+	if !ok {
+		return nil
+	}
+	return assemble(root, entries)
+}
+
+// Trees returns all of the trees, in the order in which their first entries
+// were added.
+func (b *Builder) Trees() []*Tree {
+	trees := make([]*Tree, 0, len(b.roots))
+	for _, root := range b.roots {
+		trees = append(trees, assemble(root, b.entries[root]))
+	}
+	return trees
+}
+
+// Build assembles the given entries into trees, in the order in which their
+// first entries appear.
+func Build(entries []lunk.Entry) []*Tree {
+	b := NewBuilder()
+	for _, e := range entries {
+		b.Add(e)
+	}
+	return b.Trees()
+}
+
+// ReadTrees reads all of the entries from the given EntryReader and assembles
+// them into trees, in the order in which their first entries appear.
+func ReadTrees(r lunk.EntryReader) ([]*Tree, error) {
+	b := NewBuilder()
+	for {
+		e, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return b.Trees(), nil
+			}
+			return nil, err
+		}
+		b.Add(e)
+	}
+}
+
+func assemble(root lunk.ID, entries []lunk.Entry) *Tree {
+	t := &Tree{
+		ID:    root,
+		Nodes: make(map[lunk.ID]*Node, len(entries)),
+	}
+
+	for _, e := range entries {
+		if _, ok := t.Nodes[e.ID]; ok {
+			t.Duplicates = append(t.Duplicates, e)
+			continue
+		}
+		t.Nodes[e.ID] = &Node{Entry: e}
+	}
+
+	for _, n := range t.Nodes {
+		if n.Entry.Parent == 0 {
+			continue
+		}
+
+		if p, ok := t.Nodes[n.Entry.Parent]; ok {
+			n.Parent = p
+			p.Children = append(p.Children, n)
+		} else {
+			t.Orphans = append(t.Orphans, n)
+		}
+	}
+
+	t.breakCycles()
+
+	for _, n := range t.Nodes {
+		if n.Parent == nil {
+			t.Roots = append(t.Roots, n)
+		}
+		sortNodes(n.Children)
+	}
+	sortNodes(t.Orphans)
+	sortNodes(t.Roots)
+
+	if n, ok := t.Nodes[root]; ok && n.Parent == nil {
+		t.Root = n
+		for i, r := range t.Roots {
+			if r == n {
+				copy(t.Roots[1:i+1], t.Roots[:i])
+				t.Roots[0] = n
+				break
+			}
+		}
+	}
+
+	return t
+}
+
+// breakCycles finds the nodes which aren't reachable from any parentless node,
+// which must be in or below cycles, and breaks those cycles.
+func (t *Tree) breakCycles() {
+	seen := make(map[*Node]bool, len(t.Nodes))
+	for _, n := range t.Nodes {
+		if n.Parent == nil {
+			walk(n, 0, func(n *Node, depth int) {
+				seen[n] = true
+			})
+		}
+	}
+
+	if len(seen) == len(t.Nodes) {
+		return
+	}
+
+	// visit the nodes in a stable order, so the results are deterministic
+	nodes := make([]*Node, 0, len(t.Nodes)-len(seen))
+	for _, n := range t.Nodes {
+		if !seen[n] {
+			nodes = append(nodes, n)
+		}
+	}
+	sortNodes(nodes)
+
+	for _, n := range nodes {
+		if seen[n] {
+			continue
+		}
+
+		// follow the node's parents until one repeats, which must be in
+		// the cycle
+		path := make(map[*Node]bool)
+		for !path[n] {
+			path[n] = true
+			n = n.Parent
+		}
+
+		var cycle []*Node
+		first := n
+		for c := n; ; {
+			cycle = append(cycle, c)
+			if before(c, first) {
+				first = c
+			}
+			if c = c.Parent; c == n {
+				break
+			}
+		}
+
+		// start the cycle with the earliest node, then detach it
+		for cycle[0] != first {
+			cycle = append(cycle[1:], cycle[0])
+		}
+		detach(first)
+		t.Cycles = append(t.Cycles, cycle)
+
+		walk(first, 0, func(n *Node, depth int) {
+			seen[n] = true
+		})
+	}
+}
+
+func detach(n *Node) {
+	p := n.Parent
+	for i, c := range p.Children {
+		if c == n {
+			p.Children = append(p.Children[:i], p.Children[i+1:]...)
+			break
+		}
+	}
+	n.Parent = nil
+}
+
+func sortNodes(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return before(nodes[i], nodes[j])
+	})
+}
+
+// before returns whether a was logged before b, ordering by ID when the times
+// are equal.
+func before(a, b *Node) bool {
+	if !a.Entry.Time.Equal(b.Entry.Time) {
+		return a.Entry.Time.Before(b.Entry.Time)
+	}
+	return a.Entry.ID < b.Entry.ID
+}
@@ -0,0 +1,203 @@
+package tree
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+var start = time.Date(2014, 5, 20, 14, 42, 38, 0, time.UTC)
+
+func entry(root, id, parent lunk.ID, ms int) lunk.Entry {
+	return lunk.Entry{
+		EventID: lunk.EventID{
+			Root:   root,
+			ID:     id,
+			Parent: parent,
+		},
+		Schema: "event",
+		Time:   start.Add(time.Duration(ms) * time.Millisecond),
+	}
+}
+
+func ids(nodes []*Node) []lunk.ID {
+	var ids []lunk.ID
+	for _, n := range nodes {
+		ids = append(ids, n.Entry.ID)
+	}
+	return ids
+}
+
+func testIDs(t *testing.T, name string, actual []*Node, expected ...lunk.ID) {
+	if a := ids(actual); !reflect.DeepEqual(a, expected) {
+		t.Errorf("%s was %v, but expected %v", name, a, expected)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	trees := Build([]lunk.Entry{
+		entry(1, 4, 2, 40),
+		entry(1, 3, 2, 30),
+		entry(5, 6, 5, 10),
+		entry(1, 2, 1, 50),
+		entry(1, 1, 0, 60),
+		entry(5, 5, 0, 20),
+	})
+
+	if len(trees) != 2 {
+		t.Fatalf("Was %d trees, but expected 2", len(trees))
+	}
+
+	tree := trees[0]
+	if tree.ID != 1 {
+		t.Errorf("Was %v, but expected %v", tree.ID, lunk.ID(1))
+	}
+
+	if tree.Root != tree.Nodes[1] {
+		t.Errorf("Unexpected root: %#v", tree.Root)
+	}
+
+	testIDs(t, "Roots", tree.Roots, 1)
+	testIDs(t, "Children", tree.Nodes[2].Children, 3, 4)
+	testIDs(t, "DepthFirst", tree.DepthFirst(), 1, 2, 3, 4)
+	testIDs(t, "BreadthFirst", tree.BreadthFirst(), 1, 2, 3, 4)
+	testIDs(t, "Ancestors", tree.Nodes[4].Ancestors(), 2, 1)
+	testIDs(t, "Descendants", tree.Nodes[1].Descendants(), 2, 3, 4)
+
+	if d := tree.Nodes[4].Depth(); d != 2 {
+		t.Errorf("Was %d, but expected %d", d, 2)
+	}
+
+	if len(tree.Orphans) != 0 || len(tree.Duplicates) != 0 || len(tree.Cycles) != 0 {
+		t.Errorf("Unexpected problems: %#v", tree)
+	}
+
+	testIDs(t, "DepthFirst", trees[1].DepthFirst(), 5, 6)
+}
+
+func TestBuildTraversal(t *testing.T) {
+	tree := Build([]lunk.Entry{
+		entry(1, 1, 0, 0),
+		entry(1, 2, 1, 1),
+		entry(1, 3, 2, 2),
+		entry(1, 4, 1, 3),
+		entry(1, 5, 4, 4),
+	})[0]
+
+	testIDs(t, "DepthFirst", tree.DepthFirst(), 1, 2, 3, 4, 5)
+	testIDs(t, "BreadthFirst", tree.BreadthFirst(), 1, 2, 4, 3, 5)
+
+	var depths []int
+	tree.Walk(func(n *Node, depth int) {
+		depths = append(depths, depth)
+	})
+
+	expected := []int{0, 1, 2, 1, 2}
+	if !reflect.DeepEqual(depths, expected) {
+		t.Errorf("Was %v, but expected %v", depths, expected)
+	}
+}
+
+func TestBuildOrphans(t *testing.T) {
+	tree := Build([]lunk.Entry{
+		entry(1, 1, 0, 50),
+		entry(1, 3, 2, 10),
+		entry(1, 4, 3, 20),
+		entry(1, 5, 9, 0),
+	})[0]
+
+	testIDs(t, "Roots", tree.Roots, 1, 5, 3)
+	testIDs(t, "Orphans", tree.Orphans, 5, 3)
+	testIDs(t, "Descendants", tree.Nodes[3].Descendants(), 4)
+}
+
+func TestBuildMissingRoot(t *testing.T) {
+	tree := Build([]lunk.Entry{
+		entry(1, 2, 1, 0),
+	})[0]
+
+	if tree.Root != nil {
+		t.Errorf("Unexpected root: %#v", tree.Root)
+	}
+
+	testIDs(t, "Orphans", tree.Orphans, 2)
+}
+
+func TestBuildDuplicates(t *testing.T) {
+	dupe := entry(1, 2, 1, 20)
+	dupe.Host = "dupe"
+
+	tree := Build([]lunk.Entry{
+		entry(1, 1, 0, 0),
+		entry(1, 2, 1, 10),
+		dupe,
+	})[0]
+
+	if !reflect.DeepEqual(tree.Duplicates, []lunk.Entry{dupe}) {
+		t.Errorf("Was %#v, but expected %#v", tree.Duplicates, []lunk.Entry{dupe})
+	}
+
+	if h := tree.Nodes[2].Entry.Host; h != "" {
+		t.Errorf("Unexpected host: %q", h)
+	}
+}
+
+func TestBuildCycles(t *testing.T) {
+	tree := Build([]lunk.Entry{
+		entry(1, 1, 0, 0),
+		entry(1, 2, 4, 30),
+		entry(1, 3, 2, 20),
+		entry(1, 4, 3, 10),
+		entry(1, 5, 3, 40),
+		entry(1, 6, 6, 50),
+	})[0]
+
+	if len(tree.Cycles) != 2 {
+		t.Fatalf("Was %d cycles, but expected 2", len(tree.Cycles))
+	}
+
+	testIDs(t, "Cycle", tree.Cycles[0], 4, 3, 2)
+	testIDs(t, "Cycle", tree.Cycles[1], 6)
+	testIDs(t, "Roots", tree.Roots, 1, 4, 6)
+	testIDs(t, "DepthFirst", tree.DepthFirst(), 1, 4, 2, 3, 5, 6)
+}
+
+func TestReadTrees(t *testing.T) {
+	r := lunk.NewJSONEntryReader(strings.NewReader(
+		`{"root":"0000000000000001","id":"0000000000000001","schema":"a","time":"2014-05-20T14:42:38Z"}
+{"root":"0000000000000001","id":"0000000000000002","parent":"0000000000000001","schema":"b","time":"2014-05-20T14:42:38Z"}
+`))
+
+	trees, err := ReadTrees(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testIDs(t, "DepthFirst", trees[0].DepthFirst(), 1, 2)
+}
+
+func TestReadTreesError(t *testing.T) {
+	r := lunk.NewJSONEntryReader(strings.NewReader("woo\n"))
+
+	if _, err := ReadTrees(r); err == nil {
+		t.Error("Expected an error but none was returned")
+	}
+}
+
+func TestBuilderTree(t *testing.T) {
+	b := NewBuilder()
+	if err := b.Record(entry(1, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if tree := b.Tree(1); tree == nil || tree.Root == nil {
+		t.Errorf("Unexpected tree: %#v", tree)
+	}
+
+	if tree := b.Tree(2); tree != nil {
+		t.Errorf("Unexpected tree: %#v", tree)
+	}
+}
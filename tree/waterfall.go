@@ -0,0 +1,163 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultWaterfallWidth is the width, in characters, of a Waterfall's
+	// timeline if none is set.
+	DefaultWaterfallWidth = 40
+)
+
+// A Waterfall renders trees as indented, time-aligned ASCII waterfalls, with a
+// line per event in depth-first order. Each line shows the event's schema,
+// indented by depth; its host; its start time, as an offset from the start of
+// the tree; its elapsed time; a bar showing when it occurred; and any of the
+// selected properties which it has.
+//
+// Bars for events on the tree's critical path are drawn with '#', those for
+// other events with '=', and those for instantaneous events with '|'.
+type Waterfall struct {
+	// Width is the width, in characters, of the timeline.
+	Width int
+
+	// Properties are the names of the properties to show for each event.
+	Properties []string
+}
+
+// WriteWaterfall writes the given tree to the given writer as a waterfall with
+// the default width and no properties.
+func WriteWaterfall(w io.Writer, t *Tree) error {
+	return Waterfall{}.Write(w, t)
+}
+
+// Write writes the given tree to the given writer as a waterfall.
+func (wf Waterfall) Write(w io.Writer, t *Tree) error {
+	width := wf.Width
+	if width <= 0 {
+		width = DefaultWaterfallWidth
+	}
+
+	a := Analyze(t)
+
+	critical := make(map[*Node]bool, len(a.CriticalPath))
+	for _, n := range a.CriticalPath {
+		critical[n] = true
+	}
+
+	var min, max time.Time
+	for _, timing := range a.Timings {
+		if min.IsZero() || timing.Start.Before(min) {
+			min = timing.Start
+		}
+		if max.IsZero() || timing.End.After(max) {
+			max = timing.End
+		}
+	}
+	total := max.Sub(min)
+
+	if _, err := fmt.Fprintf(w, "tree %s: %d events over %sms\n", t.ID, len(t.Nodes), formatMS(total)); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "SCHEMA\tHOST\tSTART\tELAPSED\tTIMELINE"); err != nil {
+		return err
+	}
+
+	var err error
+	t.Walk(func(n *Node, depth int) {
+		if err != nil {
+			return
+		}
+
+		timing := a.Timings[n.Entry.ID]
+
+		elapsed := "-"
+		if timing.Timed {
+			elapsed = formatMS(timing.Elapsed) + "ms"
+		}
+
+		line := fmt.Sprintf("%s%s\t%s\t+%sms\t%s\t|%s|",
+			strings.Repeat("  ", depth),
+			n.Entry.Schema,
+			n.Entry.Host,
+			formatMS(timing.Start.Sub(min)),
+			elapsed,
+			bar(timing, critical[n], min, total, width),
+		)
+		if props := wf.properties(n); props != "" {
+			line += "\t" + props
+		}
+		_, err = fmt.Fprintln(tw, line)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var problems []string
+	if n := len(t.Orphans); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d orphans", n))
+	}
+	if n := len(t.Duplicates); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d duplicates", n))
+	}
+	if n := len(t.Cycles); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d cycles", n))
+	}
+
+	if len(problems) > 0 {
+		_, err = fmt.Fprintf(w, "incomplete tree: %s\n", strings.Join(problems, ", "))
+	}
+	return err
+}
+
+func (wf Waterfall) properties(n *Node) string {
+	var props []string
+	for _, k := range wf.Properties {
+		if v, ok := n.Entry.Properties[k]; ok {
+			props = append(props, fmt.Sprintf("%s=%s", k, strconv.Quote(v)))
+		}
+	}
+	return strings.Join(props, " ")
+}
+
+// bar returns the timeline bar for the given timing.
+func bar(t *Timing, critical bool, min time.Time, total time.Duration, width int) string {
+	if total <= 0 {
+		total = 1
+	}
+
+	from := int(float64(t.Start.Sub(min)) / float64(total) * float64(width))
+	to := int(math.Ceil(float64(t.End.Sub(min)) / float64(total) * float64(width)))
+	if from >= width {
+		from = width - 1
+	}
+	if to <= from {
+		to = from + 1
+	}
+
+	c := "="
+	if !t.Timed {
+		c = "|"
+	} else if critical {
+		c = "#"
+	}
+
+	return strings.Repeat(" ", from) + strings.Repeat(c, to-from) + strings.Repeat(" ", width-to)
+}
+
+func formatMS(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
@@ -0,0 +1,65 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+func TestWaterfall(t *testing.T) {
+	root := timed(1, 0, 0, 100)
+	root.Schema = "httprequest"
+	root.Host = "edge-1"
+	root.Properties["status"] = "200"
+
+	db := timed(3, 1, 30, 65)
+	db.Schema = "query"
+	db.Host = "db-1"
+
+	msg := entry(1, 4, 3, 50)
+	msg.Schema = "message"
+	msg.Host = "db-1"
+
+	tree := Build([]lunk.Entry{
+		root,
+		timed(2, 1, 5, 20),
+		db,
+		msg,
+		timed(5, 9, 0, 10),
+	})[0]
+
+	buf := bytes.NewBuffer(nil)
+	wf := Waterfall{Width: 20, Properties: []string{"status"}}
+	if err := wf.Write(buf, tree); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `tree 0000000000000001: 5 events over 100.000ms
+SCHEMA       HOST    START      ELAPSED    TIMELINE
+httprequest  edge-1  +0.000ms   100.000ms  |####################|  status="200"
+  event              +5.000ms   20.000ms   | ####               |
+  query      db-1    +30.000ms  65.000ms   |      ############# |
+    message  db-1    +50.000ms  -          |          |         |
+event                +0.000ms   10.000ms   |==                  |
+incomplete tree: 1 orphans
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", s, expected)
+	}
+}
+
+func TestWriteWaterfall(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := WriteWaterfall(buf, Build([]lunk.Entry{entry(1, 1, 0, 0)})[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `tree 0000000000000001: 1 events over 0.000ms
+SCHEMA  HOST  START     ELAPSED  TIMELINE
+event         +0.000ms  -        ||                                       |
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Was\n%s\nbut expected\n%s", s, expected)
+	}
+}
@@ -0,0 +1,248 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/codahale/lunk"
+)
+
+const (
+	// HeaderB3TraceID is the name of the Zipkin B3 HTTP header by which the
+	// trace ID is passed along.
+	HeaderB3TraceID = "X-B3-TraceId"
+
+	// HeaderB3SpanID is the name of the Zipkin B3 HTTP header by which the
+	// span ID is passed along.
+	HeaderB3SpanID = "X-B3-SpanId"
+
+	// HeaderB3ParentSpanID is the name of the Zipkin B3 HTTP header by which
+	// the parent span ID is passed along.
+	HeaderB3ParentSpanID = "X-B3-ParentSpanId"
+
+	// HeaderB3Sampled is the name of the Zipkin B3 HTTP header by which the
+	// sampling decision is passed along.
+	HeaderB3Sampled = "X-B3-Sampled"
+
+	// HeaderB3Flags is the name of the Zipkin B3 HTTP header by which the
+	// debug flag is passed along.
+	HeaderB3Flags = "X-B3-Flags"
+
+	// HeaderB3 is the name of the single Zipkin B3 HTTP header by which the
+	// trace ID, span ID, sampling decision, and parent span ID are passed
+	// along.
+	HeaderB3 = "b3"
+)
+
+var (
+	// ErrBadB3 is returned when B3 headers cannot be parsed.
+	ErrBadB3 = errors.New("bad B3 headers")
+)
+
+// B3MultiPropagator is a Propagator which uses Zipkin's X-B3-* headers. The root
+// ID is used as the trace ID, the event ID as the span ID, and the parent ID as
+// the parent span ID. When extracting 128-bit trace IDs, the low 64 bits are
+// used as the root ID, and Handler and Transport send the full trace ID along
+// with it.
+//
+// Sampling decisions are mapped to the sampled and debug flags, which are
+// omitted if there is no decision. Headers which contain only a sampling
+// decision are extracted as an EventID with no IDs, and Handler and Transport
+// start new trees with that decision.
+type B3MultiPropagator struct{}
+
+// Extract returns the EventID from the request's X-B3-* headers.
+func (B3MultiPropagator) Extract(r *http.Request) (*lunk.EventID, error) {
+	traceID, spanID := r.Header.Get(HeaderB3TraceID), r.Header.Get(HeaderB3SpanID)
+
+	var sampling lunk.SamplingDecision
+	switch s := r.Header.Get(HeaderB3Sampled); s {
+	case "":
+	case "true": // some older implementations use "true" and "false"
+		sampling = lunk.SamplingKeep
+	case "false":
+		sampling = lunk.SamplingDrop
+	default:
+		if !isB3Sampled(s) {
+			return nil, ErrBadB3
+		}
+		sampling = b3Sampling(s)
+	}
+
+	switch r.Header.Get(HeaderB3Flags) {
+	case "":
+	case "1":
+		sampling = lunk.SamplingDebug
+	default:
+		return nil, ErrBadB3
+	}
+
+	if traceID == "" && spanID == "" {
+		if sampling == lunk.SamplingUndecided {
+			return nil, nil
+		}
+		return &lunk.EventID{Sampling: sampling}, nil
+	}
+
+	id, err := parseB3(traceID, spanID, r.Header.Get(HeaderB3ParentSpanID))
+	if err != nil {
+		return nil, err
+	}
+	id.Sampling = sampling
+	return id, nil
+}
+
+// Inject sets the request's X-B3-* headers.
+func (B3MultiPropagator) Inject(r *http.Request, id lunk.EventID) {
+	r.Header.Set(HeaderB3TraceID, b3TraceID(r, id.Root))
+	r.Header.Set(HeaderB3SpanID, id.ID.String())
+	if id.Parent != 0 {
+		r.Header.Set(HeaderB3ParentSpanID, id.Parent.String())
+	} else {
+		r.Header.Del(HeaderB3ParentSpanID)
+	}
+
+	r.Header.Del(HeaderB3Sampled)
+	r.Header.Del(HeaderB3Flags)
+	switch id.Sampling {
+	case lunk.SamplingDrop:
+		r.Header.Set(HeaderB3Sampled, "0")
+	case lunk.SamplingKeep:
+		r.Header.Set(HeaderB3Sampled, "1")
+	case lunk.SamplingDebug:
+		// debug implies sampled, so the sampled header is omitted
+		r.Header.Set(HeaderB3Flags, "1")
+	}
+}
+
+// B3SinglePropagator is a Propagator which uses Zipkin's single b3 header. IDs
+// and sampling decisions are mapped as with B3MultiPropagator.
+type B3SinglePropagator struct{}
+
+// Extract returns the EventID from the request's b3 header. A header which
+// contains only a sampling decision results in an EventID with no IDs.
+func (B3SinglePropagator) Extract(r *http.Request) (*lunk.EventID, error) {
+	s := r.Header.Get(HeaderB3)
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, "-")
+	switch len(parts) {
+	case 1:
+		if !isB3Sampled(parts[0]) {
+			return nil, ErrBadB3
+		}
+		return &lunk.EventID{Sampling: b3Sampling(parts[0])}, nil
+	case 2:
+		return parseB3(parts[0], parts[1], "")
+	case 3, 4:
+		if !isB3Sampled(parts[2]) {
+			return nil, ErrBadB3
+		}
+
+		var parent string
+		if len(parts) == 4 {
+			parent = parts[3]
+			if parent == "" {
+				return nil, ErrBadB3
+			}
+		}
+
+		id, err := parseB3(parts[0], parts[1], parent)
+		if err != nil {
+			return nil, err
+		}
+		id.Sampling = b3Sampling(parts[2])
+		return id, nil
+	}
+	return nil, ErrBadB3
+}
+
+// Inject sets the request's b3 header. If the EventID has no sampling decision,
+// the header contains only the trace and span IDs, since the parent span ID
+// can't be sent without a sampling decision.
+func (B3SinglePropagator) Inject(r *http.Request, id lunk.EventID) {
+	s := b3TraceID(r, id.Root) + "-" + id.ID.String()
+	if id.Sampling != lunk.SamplingUndecided {
+		s += "-" + b3Flag(id.Sampling)
+		if id.Parent != 0 {
+			s += "-" + id.Parent.String()
+		}
+	}
+	r.Header.Set(HeaderB3, s)
+}
+
+// b3TraceID returns the 128-bit trace ID which the request's context carries for
+// the given root ID, if any, or the root ID.
+func b3TraceID(r *http.Request, root lunk.ID) string {
+	if s, ok := fullTraceIDFor(r.Context(), root); ok {
+		return s
+	}
+	return root.String()
+}
+
+func parseB3(traceID, spanID, parentSpanID string) (*lunk.EventID, error) {
+	root, err := parseTraceID(traceID)
+	if err != nil {
+		return nil, ErrBadB3
+	}
+
+	id, err := parseSpanID(spanID)
+	if err != nil {
+		return nil, ErrBadB3
+	}
+
+	var parent lunk.ID
+	if parentSpanID != "" {
+		parent, err = parseSpanID(parentSpanID)
+		if err != nil {
+			return nil, ErrBadB3
+		}
+	}
+
+	return &lunk.EventID{
+		Root:   root,
+		ID:     id,
+		Parent: parent,
+	}, nil
+}
+
+func parseSpanID(s string) (lunk.ID, error) {
+	if len(s) != 16 || !isLowerHex(s) {
+		return 0, ErrBadB3
+	}
+
+	id, err := lunk.ParseID(s)
+	if err != nil || id == 0 {
+		return 0, ErrBadB3
+	}
+	return id, nil
+}
+
+func isB3Sampled(s string) bool {
+	return s == "0" || s == "1" || s == "d"
+}
+
+// b3Sampling returns the sampling decision for a valid B3 sampling state.
+func b3Sampling(s string) lunk.SamplingDecision {
+	switch s {
+	case "0":
+		return lunk.SamplingDrop
+	case "d":
+		return lunk.SamplingDebug
+	}
+	return lunk.SamplingKeep
+}
+
+// b3Flag returns the B3 sampling state for a sampling decision.
+func b3Flag(d lunk.SamplingDecision) string {
+	switch d {
+	case lunk.SamplingDrop:
+		return "0"
+	case lunk.SamplingDebug:
+		return "d"
+	}
+	return "1"
+}
@@ -0,0 +1,387 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+var (
+	_ Propagator = B3MultiPropagator{}
+	_ Propagator = B3SinglePropagator{}
+)
+
+func TestB3MultiPropagatorInject(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+
+	B3MultiPropagator{}.Inject(&r, lunk.EventID{
+		Root:   100,
+		ID:     300,
+		Parent: 150,
+	})
+
+	expected := http.Header{
+		"X-B3-Traceid":      []string{"0000000000000064"},
+		"X-B3-Spanid":       []string{"000000000000012c"},
+		"X-B3-Parentspanid": []string{"0000000000000096"},
+	}
+	if !reflect.DeepEqual(r.Header, expected) {
+		t.Errorf("Was %#v, but expected %#v", r.Header, expected)
+	}
+}
+
+func TestB3MultiPropagatorExtract(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Set("X-B3-TraceId", "80f198ee56343ba864fe8b2a57d3eff7")
+	r.Header.Set("X-B3-SpanId", "e457b5a2e4d86bd1")
+	r.Header.Set("X-B3-ParentSpanId", "05e3ac9a4f6e3b90")
+	r.Header.Set("X-B3-Sampled", "1")
+
+	id, err := B3MultiPropagator{}.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := lunk.EventID{
+		Root:     0x64fe8b2a57d3eff7,
+		ID:       0xe457b5a2e4d86bd1,
+		Parent:   0x05e3ac9a4f6e3b90,
+		Sampling: lunk.SamplingKeep,
+	}
+	if *id != expected {
+		t.Errorf("Was %+v, but expected %+v", *id, expected)
+	}
+}
+
+func TestB3MultiPropagatorSampling(t *testing.T) {
+	for _, d := range []lunk.SamplingDecision{
+		lunk.SamplingDrop,
+		lunk.SamplingKeep,
+		lunk.SamplingDebug,
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		r.Header.Set("X-B3-Flags", "1")
+
+		B3MultiPropagator{}.Inject(&r, lunk.EventID{Root: 100, ID: 300, Sampling: d})
+
+		id, err := B3MultiPropagator{}.Extract(&r)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if id.Sampling != d {
+			t.Errorf("Was %v, but expected %v", id.Sampling, d)
+		}
+	}
+
+	r := http.Request{
+		Header: http.Header{},
+	}
+	B3MultiPropagator{}.Inject(&r, lunk.EventID{Root: 100, ID: 300, Sampling: lunk.SamplingDebug})
+
+	if v := r.Header.Get("X-B3-Flags"); v != "1" {
+		t.Errorf("Was %#v, but expected %#v", v, "1")
+	}
+
+	if v, ok := r.Header["X-B3-Sampled"]; ok {
+		t.Errorf("Unexpected X-B3-Sampled header: %v", v)
+	}
+}
+
+func TestB3MultiPropagatorExtractMissing(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+
+	id, err := B3MultiPropagator{}.Extract(&r)
+	if id != nil {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestB3MultiPropagatorExtractSamplingOnly(t *testing.T) {
+	for h, expected := range map[string]lunk.SamplingDecision{
+		"X-B3-Sampled": lunk.SamplingDrop,
+		"X-B3-Flags":   lunk.SamplingDebug,
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		v := "0"
+		if h == "X-B3-Flags" {
+			v = "1"
+		}
+		r.Header.Set(h, v)
+
+		id, err := B3MultiPropagator{}.Extract(&r)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if *id != (lunk.EventID{Sampling: expected}) {
+			t.Errorf("Was %+v, but expected %+v", *id, lunk.EventID{Sampling: expected})
+		}
+	}
+}
+
+func TestB3MultiPropagatorExtractMalformed(t *testing.T) {
+	for _, h := range []map[string]string{
+		{"X-B3-TraceId": "64fe8b2a57d3eff7"},
+		{"X-B3-SpanId": "e457b5a2e4d86bd1"},
+		{"X-B3-TraceId": "64fe8b2a57d3eff", "X-B3-SpanId": "e457b5a2e4d86bd1"},
+		{"X-B3-TraceId": "64fe8b2a57d3eff7", "X-B3-SpanId": "e457b5a2e4d86bdg"},
+		{"X-B3-TraceId": "64fe8b2a57d3eff7", "X-B3-SpanId": "e457b5a2e4d86bd1", "X-B3-ParentSpanId": "woo"},
+		{"X-B3-TraceId": "64fe8b2a57d3eff7", "X-B3-SpanId": "e457b5a2e4d86bd1", "X-B3-Sampled": "yes"},
+		{"X-B3-TraceId": "64fe8b2a57d3eff7", "X-B3-SpanId": "e457b5a2e4d86bd1", "X-B3-Flags": "0"},
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		for k, v := range h {
+			r.Header.Set(k, v)
+		}
+
+		id, err := B3MultiPropagator{}.Extract(&r)
+		if id != nil {
+			t.Errorf("Unexpected event ID for %v: %+v", h, id)
+		}
+
+		if err != ErrBadB3 {
+			t.Errorf("Unexpected error for %v: %v", h, err)
+		}
+	}
+}
+
+func TestB3SinglePropagatorInject(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+
+	B3SinglePropagator{}.Inject(&r, lunk.EventID{
+		Root:   100,
+		ID:     300,
+		Parent: 150,
+	})
+
+	// the parent can't be sent without a sampling decision
+	actual := r.Header.Get("b3")
+	expected := "0000000000000064-000000000000012c"
+	if actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+
+	B3SinglePropagator{}.Inject(&r, lunk.EventID{
+		Root:     100,
+		ID:       300,
+		Parent:   150,
+		Sampling: lunk.SamplingKeep,
+	})
+
+	actual = r.Header.Get("b3")
+	expected = "0000000000000064-000000000000012c-1-0000000000000096"
+	if actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+
+	B3SinglePropagator{}.Inject(&r, lunk.EventID{
+		Root:     100,
+		ID:       300,
+		Sampling: lunk.SamplingDebug,
+	})
+
+	actual = r.Header.Get("b3")
+	expected = "0000000000000064-000000000000012c-d"
+	if actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+}
+
+func TestB3SinglePropagatorExtract(t *testing.T) {
+	for s, expected := range map[string]lunk.EventID{
+		"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90": {
+			Root:     0x64fe8b2a57d3eff7,
+			ID:       0xe457b5a2e4d86bd1,
+			Parent:   0x05e3ac9a4f6e3b90,
+			Sampling: lunk.SamplingKeep,
+		},
+		"64fe8b2a57d3eff7-e457b5a2e4d86bd1-d": {
+			Root:     0x64fe8b2a57d3eff7,
+			ID:       0xe457b5a2e4d86bd1,
+			Sampling: lunk.SamplingDebug,
+		},
+		"64fe8b2a57d3eff7-e457b5a2e4d86bd1-0": {
+			Root:     0x64fe8b2a57d3eff7,
+			ID:       0xe457b5a2e4d86bd1,
+			Sampling: lunk.SamplingDrop,
+		},
+		"64fe8b2a57d3eff7-e457b5a2e4d86bd1": {
+			Root: 0x64fe8b2a57d3eff7,
+			ID:   0xe457b5a2e4d86bd1,
+		},
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		r.Header.Set("b3", s)
+
+		id, err := B3SinglePropagator{}.Extract(&r)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", s, err)
+		}
+
+		if *id != expected {
+			t.Errorf("Was %+v, but expected %+v", *id, expected)
+		}
+	}
+}
+
+func TestB3SinglePropagatorExtractSamplingOnly(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Set("b3", "0")
+
+	id, err := B3SinglePropagator{}.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if *id != (lunk.EventID{Sampling: lunk.SamplingDrop}) {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestB3SamplingOnlyPropagation(t *testing.T) {
+	l := &fakeLogger{}
+	var sent http.Header
+	client := &http.Client{
+		Transport: &Transport{
+			Logger:     l,
+			Propagator: B3SinglePropagator{},
+			Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				sent = r.Header
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			}),
+		},
+	}
+
+	h := HandlerWithPropagator(l, B3SinglePropagator{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Do(req.WithContext(r.Context())); err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/woo", nil)
+	r.Header.Set("b3", "0")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	outbound, server := l.events[0].id, l.events[1].id
+	if server.Root == 0 || server.Parent != 0 || server.Sampling != lunk.SamplingDrop {
+		t.Errorf("Unexpected server event ID: %+v", server)
+	}
+
+	if outbound.Parent != server.ID || outbound.Sampling != lunk.SamplingDrop {
+		t.Errorf("Unexpected client event ID: %+v", outbound)
+	}
+
+	expected := outbound.Root.String() + "-" + outbound.ID.String() + "-0-" + server.ID.String()
+	if actual := sent.Get("b3"); actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+}
+
+func TestB3SinglePropagatorExtractMalformed(t *testing.T) {
+	for _, s := range []string{
+		"woo",
+		"64fe8b2a57d3eff7",
+		"64fe8b2a57d3eff7-e457b5a2e4d86bd1-2",
+		"64fe8b2a57d3eff7-e457b5a2e4d86bd1-1-",
+		"64fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90-woo",
+		"00000000000000000000000000000000-e457b5a2e4d86bd1",
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		r.Header.Set("b3", s)
+
+		id, err := B3SinglePropagator{}.Extract(&r)
+		if id != nil {
+			t.Errorf("Unexpected event ID for %q: %+v", s, id)
+		}
+
+		if err != ErrBadB3 {
+			t.Errorf("Unexpected error for %q: %v", s, err)
+		}
+	}
+}
+
+func TestB3Propagation128BitTraceID(t *testing.T) {
+	for _, p := range []Propagator{B3MultiPropagator{}, B3SinglePropagator{}} {
+		l := &fakeLogger{}
+		var sent *http.Request
+		client := &http.Client{
+			Transport: &Transport{
+				Logger:     l,
+				Propagator: p,
+				Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					sent = r
+					return &http.Response{StatusCode: http.StatusOK}, nil
+				}),
+			},
+		}
+
+		h := HandlerWithPropagator(l, p, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := http.NewRequest("GET", "http://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.Do(req.WithContext(r.Context())); err != nil {
+				t.Fatal(err)
+			}
+		}))
+
+		r := httptest.NewRequest("GET", "/woo", nil)
+		r.Header.Set("X-B3-TraceId", "80f198ee56343ba864fe8b2a57d3eff7")
+		r.Header.Set("X-B3-SpanId", "e457b5a2e4d86bd1")
+		r.Header.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		id, err := p.Extract(sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id.Root != 0x64fe8b2a57d3eff7 {
+			t.Errorf("Unexpected event ID: %+v", id)
+		}
+
+		actual := sent.Header.Get("X-B3-TraceId")
+		if _, ok := p.(B3SinglePropagator); ok {
+			actual = strings.Split(sent.Header.Get("b3"), "-")[0]
+		}
+
+		if expected := "80f198ee56343ba864fe8b2a57d3eff7"; actual != expected {
+			t.Errorf("Was %#v, but expected %#v", actual, expected)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+// Handler returns an http.Handler which logs an HTTPRequestEvent for each
+// request handled by the given handler.
+//
+// If DefaultPropagator extracts a valid EventID from the request, the logged
+// event is a child of that event. Otherwise, the logged event is a new root
+// event. The request's context carries the ID of the logged event, so the inner
+// handler can use lunk.FromContext or lunk.ChildContext to properly parent its
+// own events. It also carries the request's tracestate header, and the full
+// 128-bit trace ID and sampled flag of the traceparent header from which the
+// root ID was taken, if any, all of which Transport passes along. If the inner handler panics before writing a
+// response, the event is logged with a 500 status and the panic is re-raised.
+func Handler(l lunk.EventLogger, h http.Handler) http.Handler {
+	return handler{l: l, h: h}
+}
+
+// HandlerWithPropagator returns an http.Handler which behaves like Handler, but
+// which uses the given Propagator to extract EventIDs from requests.
+func HandlerWithPropagator(l lunk.EventLogger, p Propagator, h http.Handler) http.Handler {
+	return handler{l: l, p: p, h: h}
+}
+
+type handler struct {
+	l lunk.EventLogger
+	p Propagator
+	h http.Handler
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	p := h.p
+	if p == nil {
+		p = DefaultPropagator
+	}
+
+	var id lunk.EventID
+	if parent, err := p.Extract(r); err == nil && parent != nil {
+		id = childEventID(*parent)
+	} else {
+		id = lunk.NewRootEventID()
+	}
+
+	e := HTTPRequest(r)
+	ctx := withTraceFlags(withTraceID(withTraceState(r.Context(), r), r, id.Root), r, id.Root)
+	r = r.WithContext(lunk.NewContext(ctx, id))
+
+	rw := &responseWriter{ResponseWriter: w}
+	defer func() {
+		err := recover()
+
+		e.Status = rw.status
+		if e.Status == 0 && err != nil {
+			e.Status = http.StatusInternalServerError // the handler panicked
+		} else if e.Status == 0 {
+			e.Status = http.StatusOK // nothing written, so net/http sends a 200
+		}
+		e.ResponseSize = rw.size
+		e.Elapsed = time.Now().Sub(start)
+		h.l.Log(id, e)
+
+		if err != nil {
+			panic(err) // let net/http deal with it
+		}
+	}()
+
+	h.h.ServeHTTP(rw, r)
+}
+
+// responseWriter records the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush passes the flush along to the underlying http.ResponseWriter, if it
+// supports flushing.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, which allows
+// http.ResponseController to reach its optional methods.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack passes the hijack along to the underlying http.ResponseWriter, if it
+// supports hijacking (e.g., for WebSocket upgrades).
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Push passes the push along to the underlying http.ResponseWriter, if it
+// supports HTTP/2 server push.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom passes the read along to the underlying http.ResponseWriter, if it
+// is an io.ReaderFrom (e.g., to use sendfile), recording the response size.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		// hide ReadFrom from io.Copy, which would otherwise call it again
+		return io.Copy(struct{ io.Writer }{w}, r)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := rf.ReadFrom(r)
+	w.size += n
+	return n, err
+}
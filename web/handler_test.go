@@ -0,0 +1,191 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+func TestHandler(t *testing.T) {
+	l := &fakeLogger{}
+	var inner lunk.EventID
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner, _ = lunk.FromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	}))
+
+	r := httptest.NewRequest("GET", "/woo", nil)
+	r.Header.Set("Event-ID", "0000000000000064/0000000000000096")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if len(l.events) != 1 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	id := l.events[0].id
+	if id.Root != 100 || id.Parent != 150 || id.ID == 0 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	if inner != id {
+		t.Errorf("Inner handler saw %+v, but expected %+v", inner, id)
+	}
+
+	e := l.events[0].e.(*HTTPRequestEvent)
+	if e.Status != http.StatusTeapot {
+		t.Errorf("Unexpected status: %d", e.Status)
+	}
+
+	if e.ResponseSize != 15 {
+		t.Errorf("Unexpected response size: %d", e.ResponseSize)
+	}
+
+	if e.Elapsed <= 0 {
+		t.Errorf("Unexpected elapsed time: %v", e.Elapsed)
+	}
+
+	if e.Headers["event-id"] != "0000000000000064/0000000000000096" {
+		t.Errorf("Unexpected headers: %+v", e.Headers)
+	}
+}
+
+func TestHandlerRootEvent(t *testing.T) {
+	l := &fakeLogger{}
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	r := httptest.NewRequest("GET", "/woo", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(l.events) != 1 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	id := l.events[0].id
+	if id.Root == 0 || id.ID == 0 || id.Parent != 0 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	e := l.events[0].e.(*HTTPRequestEvent)
+	if e.Status != http.StatusOK {
+		t.Errorf("Unexpected status: %d", e.Status)
+	}
+
+	if e.ResponseSize != 2 {
+		t.Errorf("Unexpected response size: %d", e.ResponseSize)
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	l := &fakeLogger{}
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("woo")
+	}))
+
+	func() {
+		defer func() {
+			if v := recover(); v != "woo" {
+				t.Errorf("Was %v, but expected %v", v, "woo")
+			}
+		}()
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/woo", nil))
+	}()
+
+	if len(l.events) != 1 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	e := l.events[0].e.(*HTTPRequestEvent)
+	if e.Status != http.StatusInternalServerError {
+		t.Errorf("Unexpected status: %d", e.Status)
+	}
+}
+
+func TestHandlerHijack(t *testing.T) {
+	l := &fakeLogger{}
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		conn, buf, err := rc.Hijack()
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		buf.Flush()
+	}))
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Was %d, but expected %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestHandlerReadFrom(t *testing.T) {
+	l := &fakeLogger{}
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, strings.NewReader("short and stout"))
+	}))
+
+	for _, w := range []http.ResponseWriter{
+		httptest.NewRecorder(),
+		readerFromRecorder{httptest.NewRecorder()},
+	} {
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/woo", nil))
+	}
+
+	for _, le := range l.events {
+		e := le.e.(*HTTPRequestEvent)
+		if e.ResponseSize != 15 {
+			t.Errorf("Was %d, but expected %d", e.ResponseSize, 15)
+		}
+
+		if e.Status != http.StatusOK {
+			t.Errorf("Was %d, but expected %d", e.Status, http.StatusOK)
+		}
+	}
+}
+
+// readerFromRecorder is a ResponseRecorder which is an io.ReaderFrom.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (w readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+type fakeLogging struct {
+	id lunk.EventID
+	e  lunk.Event
+}
+
+type fakeLogger struct {
+	events []fakeLogging
+}
+
+func (l *fakeLogger) Log(id lunk.EventID, e lunk.Event) {
+	l.events = append(l.events, fakeLogging{id: id, e: e})
+}
@@ -37,7 +37,8 @@ var (
 
 // HTTPRequest returns an event which records various aspects of an HTTP request.
 // The returned value is incomplete, and should have the response status, size,
-// and the elapsed time set before being logged.
+// and the elapsed time set before being logged. Handler does this
+// automatically.
 func HTTPRequest(r *http.Request) *HTTPRequestEvent {
 	return &HTTPRequestEvent{
 		Method:        r.Method,
@@ -63,6 +64,7 @@ type HTTPRequestEvent struct {
 	RemoteAddr    string            `lunk:"remote_addr"`
 	ContentLength int64             `lunk:"content_length"`
 	Status        int               `lunk:"status"`
+	ResponseSize  int64             `lunk:"response_size"`
 	Elapsed       time.Duration     `lunk:"elapsed"`
 }
 
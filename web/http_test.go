@@ -80,6 +80,7 @@ func TestHTTPRequest(t *testing.T) {
 
 	e := HTTPRequest(r)
 	e.Status = 200
+	e.ResponseSize = 1024
 	e.Elapsed = 4300 * time.Microsecond
 
 	if e.Schema() != "httprequest" {
@@ -97,6 +98,7 @@ func TestHTTPRequest(t *testing.T) {
 		"host":                  "example.com",
 		"content_length":        "0",
 		"status":                "200",
+		"response_size":         "1024",
 		"method":                "GET",
 		"uri":                   "/woohoo",
 	}
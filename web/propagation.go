@@ -0,0 +1,151 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/codahale/lunk"
+)
+
+// A Propagator extracts EventIDs from and injects EventIDs into HTTP requests
+// using a particular header format.
+type Propagator interface {
+	// Extract returns the EventID for the request, nil if none was provided,
+	// or an error if the value was unparseable. If the request has a sampling
+	// decision but no IDs, the EventID has only a sampling decision.
+	Extract(r *http.Request) (*lunk.EventID, error)
+
+	// Inject sets the EventID on the request.
+	Inject(r *http.Request, id lunk.EventID)
+}
+
+var (
+	// DefaultPropagator is the Propagator used by Handler, and by Transports
+	// which don't have a Propagator. It reads and writes both Event-ID and
+	// traceparent headers, preferring Event-ID.
+	DefaultPropagator Propagator = Propagators{
+		EventIDPropagator{},
+		TraceContextPropagator{},
+	}
+)
+
+// Propagators is a Propagator which extracts EventIDs using the first of its
+// Propagators which provides a valid EventID, and which injects EventIDs using
+// all of its Propagators. Its order determines the precedence of header formats
+// when a request has more than one.
+type Propagators []Propagator
+
+// Extract returns the first valid EventID extracted by the Propagators. EventIDs
+// with only sampling decisions are returned only if none of the Propagators
+// provides one with IDs. If none of the Propagators provides a valid EventID,
+// the first error encountered, if any, is returned.
+func (ps Propagators) Extract(r *http.Request) (*lunk.EventID, error) {
+	var firstErr error
+	var decision *lunk.EventID
+	for _, p := range ps {
+		id, err := p.Extract(r)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if id != nil && id.ID == 0 {
+			if decision == nil {
+				decision = id
+			}
+			continue
+		}
+
+		if id != nil {
+			return id, nil
+		}
+	}
+
+	if decision != nil {
+		return decision, nil
+	}
+	return nil, firstErr
+}
+
+// Inject sets the EventID on the request using all of the Propagators.
+func (ps Propagators) Inject(r *http.Request, id lunk.EventID) {
+	for _, p := range ps {
+		p.Inject(r, id)
+	}
+}
+
+// childEventID returns a new EventID which is a child of the given parent or, if
+// the parent has only a sampling decision, a new root EventID with that
+// decision.
+func childEventID(parent lunk.EventID) lunk.EventID {
+	if parent.ID == 0 {
+		id := lunk.NewRootEventID()
+		id.Sampling = parent.Sampling
+		return id
+	}
+	return lunk.NewEventID(parent)
+}
+
+// EventIDPropagator is a Propagator which uses the Event-ID header.
+type EventIDPropagator struct{}
+
+// Extract returns the EventID from the request's Event-ID header.
+func (EventIDPropagator) Extract(r *http.Request) (*lunk.EventID, error) {
+	return GetRequestEventID(r)
+}
+
+// Inject sets the request's Event-ID header.
+func (EventIDPropagator) Inject(r *http.Request, id lunk.EventID) {
+	SetRequestEventID(r, id)
+}
+
+// TraceContextPropagator is a Propagator which uses the W3C Trace Context
+// traceparent header.
+//
+// Since other systems set the sampled flag for their own reasons, it isn't used
+// as a sampling decision by default. Instead, trees without sampling decisions
+// pass the flag along unchanged: if a tree's root ID came from a traceparent
+// header (see Handler), the flag of that header is sent. Trees which started
+// with lunk are sent with the flag set, unless SampleRate says otherwise.
+type TraceContextPropagator struct {
+	// TrustSampledFlag determines whether or not the sampled flag of
+	// extracted traceparent headers is used as a SamplingKeep or SamplingDrop
+	// decision. If false, extracted EventIDs have no sampling decision.
+	TrustSampledFlag bool
+
+	// SampleRate is the rate at which trees without sampling decisions or
+	// incoming sampled flags are sent with the sampled flag set, between 0.0
+	// (no trees) and 1.0 (all trees), inclusive. The decision is made with
+	// RootSampled, so it is the same for every event in a tree, and matches
+	// that of SamplingEventLoggers with deterministic sampling at the same
+	// rate. If zero, all trees are sent with the flag set; use a negative rate
+	// to send none.
+	SampleRate float64
+}
+
+// Extract returns the EventID from the request's traceparent header.
+func (p TraceContextPropagator) Extract(r *http.Request) (*lunk.EventID, error) {
+	if p.TrustSampledFlag {
+		return getTraceParent(r)
+	}
+	return GetRequestTraceParent(r)
+}
+
+// Inject sets the request's traceparent header.
+func (p TraceContextPropagator) Inject(r *http.Request, id lunk.EventID) {
+	var sampled bool
+	switch id.Sampling {
+	case lunk.SamplingKeep, lunk.SamplingDebug:
+		sampled = true
+	case lunk.SamplingUndecided:
+		if s, ok := sampledFlagFor(r.Context(), id.Root); ok {
+			sampled = s
+		} else if p.SampleRate != 0 {
+			sampled = lunk.RootSampled(id.Root, p.SampleRate)
+		} else {
+			sampled = true
+		}
+	}
+	setTraceParent(r, id, sampled)
+}
@@ -0,0 +1,186 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+var (
+	_ Propagator = Propagators{}
+	_ Propagator = EventIDPropagator{}
+	_ Propagator = TraceContextPropagator{}
+)
+
+func TestPropagatorsExtractPrecedence(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Add("Event-ID", "0000000000000064/0000000000000096")
+	r.Header.Add("traceparent", "00-000000000000000000000000000000c8-00000000000000fa-01")
+
+	p := Propagators{EventIDPropagator{}, TraceContextPropagator{}}
+	id, err := p.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id == nil || id.Root != 100 || id.ID != 150 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	p = Propagators{TraceContextPropagator{}, EventIDPropagator{}}
+	id, err = p.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id == nil || id.Root != 200 || id.ID != 250 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestPropagatorsExtractFallback(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Add("Event-ID", "woo")
+	r.Header.Add("traceparent", "00-000000000000000000000000000000c8-00000000000000fa-01")
+
+	id, err := DefaultPropagator.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id == nil || id.Root != 200 || id.ID != 250 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestPropagatorsExtractSamplingOnly(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Add("b3", "d")
+
+	p := Propagators{B3SinglePropagator{}, EventIDPropagator{}}
+	id, err := p.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id == nil || *id != (lunk.EventID{Sampling: lunk.SamplingDebug}) {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	// IDs from other propagators take precedence
+	r.Header.Add("Event-ID", "0000000000000064/0000000000000096")
+	id, err = p.Extract(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id == nil || id.Root != 100 || id.ID != 150 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestPropagatorsExtractError(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Add("Event-ID", "woo")
+
+	id, err := DefaultPropagator.Extract(&r)
+	if id != nil {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	if err != lunk.ErrBadEventID {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestPropagatorsExtractMissing(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+
+	id, err := DefaultPropagator.Extract(&r)
+	if id != nil {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestPropagatorsInject(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	DefaultPropagator.Inject(&r, lunk.EventID{Root: 100, ID: 150})
+
+	if r.Header.Get("Event-ID") == "" || r.Header.Get("traceparent") == "" {
+		t.Errorf("Unexpected headers: %+v", r.Header)
+	}
+}
+
+func TestHandlerWithPropagator(t *testing.T) {
+	l := &fakeLogger{}
+	h := HandlerWithPropagator(l, B3SinglePropagator{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/woo", nil)
+	r.Header.Set("Event-ID", "0000000000000064/0000000000000096")
+	r.Header.Set("b3", "00000000000000c8-00000000000000fa-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(l.events) != 1 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	id := l.events[0].id
+	if id.Root != 200 || id.Parent != 250 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestTransportWithPropagator(t *testing.T) {
+	l := &fakeLogger{}
+	var sent http.Header
+	tr := &Transport{
+		Logger:     l,
+		Propagator: B3MultiPropagator{},
+		Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			sent = r.Header
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}),
+	}
+
+	r, err := http.NewRequest("GET", "http://example.com/woo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("X-B3-TraceId", "00000000000000c8")
+	r.Header.Set("X-B3-SpanId", "00000000000000fa")
+
+	if _, err := tr.RoundTrip(r); err != nil {
+		t.Fatal(err)
+	}
+
+	id := l.events[0].id
+	if id.Root != 200 || id.Parent != 250 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	if sent.Get("Event-ID") != "" {
+		t.Errorf("Unexpected Event-ID header: %v", sent.Get("Event-ID"))
+	}
+
+	if sent.Get("X-B3-SpanId") != id.ID.String() || sent.Get("X-B3-ParentSpanId") != "00000000000000fa" {
+		t.Errorf("Unexpected headers: %+v", sent)
+	}
+}
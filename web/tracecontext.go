@@ -0,0 +1,252 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/codahale/lunk"
+)
+
+const (
+	// HeaderTraceParent is the name of the W3C Trace Context HTTP header by
+	// which the trace and parent IDs are passed along.
+	HeaderTraceParent = "traceparent"
+
+	// HeaderTraceState is the name of the W3C Trace Context HTTP header by
+	// which vendor-specific trace state is passed along.
+	HeaderTraceState = "tracestate"
+)
+
+var (
+	// ErrBadTraceParent is returned when the traceparent header cannot be
+	// parsed.
+	ErrBadTraceParent = errors.New("bad traceparent")
+)
+
+// SetRequestTraceParent sets the traceparent header on the request. The root ID
+// is used as the low 64 bits of the 128-bit trace ID, and the event ID is used
+// as the parent ID. If the request's context carries the 128-bit trace ID which
+// the root ID came from (see Handler), that trace ID is used instead, so that
+// the trace continues unchanged. The sampled flag is cleared if the sampling
+// decision is SamplingDrop. If there is no decision, the flag of the traceparent
+// header which the root ID came from (see Handler) is used, or, if there is
+// none, the flag is set.
+func SetRequestTraceParent(r *http.Request, e lunk.EventID) {
+	sampled := e.Sampling != lunk.SamplingDrop
+	if e.Sampling == lunk.SamplingUndecided {
+		if s, ok := sampledFlagFor(r.Context(), e.Root); ok {
+			sampled = s
+		}
+	}
+	setTraceParent(r, e, sampled)
+}
+
+func setTraceParent(r *http.Request, e lunk.EventID, sampled bool) {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	r.Header.Set(HeaderTraceParent, fmt.Sprintf("00-%s-%s-%s", traceID(r.Context(), e.Root), e.ID, flags))
+}
+
+// GetRequestTraceParent returns the EventID for the request, nil if no
+// traceparent was provided, or an error if the value was unparseable. The low
+// 64 bits of the trace ID are used as the root ID, and the parent ID is used as
+// the event ID. The sampled flag is ignored, so the EventID has no sampling
+// decision; see TraceContextPropagator to use it.
+func GetRequestTraceParent(r *http.Request) (*lunk.EventID, error) {
+	id, err := getTraceParent(r)
+	if id != nil {
+		id.Sampling = lunk.SamplingUndecided
+	}
+	return id, err
+}
+
+// getTraceParent returns the EventID for the request, with a SamplingKeep or
+// SamplingDrop decision from the sampled flag.
+func getTraceParent(r *http.Request) (*lunk.EventID, error) {
+	s := r.Header.Get(HeaderTraceParent)
+	if s == "" {
+		return nil, nil
+	}
+	return parseTraceParent(s)
+}
+
+func parseTraceParent(s string) (*lunk.EventID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 {
+		return nil, ErrBadTraceParent
+	}
+
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" ||
+		(version == "00" && len(parts) != 4) ||
+		len(traceID) != 32 || !isLowerHex(traceID) ||
+		len(parentID) != 16 || !isLowerHex(parentID) ||
+		len(flags) != 2 || !isLowerHex(flags) {
+		return nil, ErrBadTraceParent
+	}
+
+	root, err := parseTraceID(traceID)
+	if err != nil {
+		return nil, ErrBadTraceParent
+	}
+
+	id, err := lunk.ParseID(parentID)
+	if err != nil || id == 0 {
+		return nil, ErrBadTraceParent
+	}
+
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return nil, ErrBadTraceParent
+	}
+
+	sampling := lunk.SamplingDrop
+	if f&1 == 1 {
+		sampling = lunk.SamplingKeep
+	}
+
+	return &lunk.EventID{
+		Root:     root,
+		ID:       id,
+		Sampling: sampling,
+	}, nil
+}
+
+// parseTraceID parses a 64- or 128-bit hex-encoded trace ID as a root ID, using
+// the low 64 bits of 128-bit trace IDs unless they're empty.
+func parseTraceID(s string) (lunk.ID, error) {
+	if (len(s) != 16 && len(s) != 32) || !isLowerHex(s) {
+		return 0, errBadTraceID
+	}
+
+	root, err := lunk.ParseID(s[len(s)-16:])
+	if err != nil {
+		return 0, err
+	}
+
+	if root == 0 && len(s) == 32 {
+		root, err = lunk.ParseID(s[:16])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if root == 0 {
+		return 0, errBadTraceID
+	}
+	return root, nil
+}
+
+var errBadTraceID = errors.New("bad trace ID")
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type traceStateKey int
+
+// withTraceState returns a copy of the given context which carries the request's
+// tracestate header, if any.
+func withTraceState(ctx context.Context, r *http.Request) context.Context {
+	if s := r.Header.Get(HeaderTraceState); s != "" {
+		return context.WithValue(ctx, traceStateKey(0), s)
+	}
+	return ctx
+}
+
+// setTraceState sets the tracestate header carried by the given context, if any,
+// on a request which has a traceparent header but no tracestate header.
+func setTraceState(ctx context.Context, r *http.Request) {
+	s, ok := ctx.Value(traceStateKey(0)).(string)
+	if ok && r.Header.Get(HeaderTraceParent) != "" && r.Header.Get(HeaderTraceState) == "" {
+		r.Header.Set(HeaderTraceState, s)
+	}
+}
+
+type traceFlagsKey int
+
+// traceFlags is the sampled flag of the traceparent header from which a root ID
+// was taken.
+type traceFlags struct {
+	root    lunk.ID
+	sampled bool
+}
+
+// withTraceFlags returns a copy of the given context which carries the sampled
+// flag of the request's traceparent header, if the given root ID was taken from
+// it.
+func withTraceFlags(ctx context.Context, r *http.Request, root lunk.ID) context.Context {
+	if id, err := getTraceParent(r); err == nil && id != nil && id.Root == root {
+		f := traceFlags{root: root, sampled: id.Sampling == lunk.SamplingKeep}
+		return context.WithValue(ctx, traceFlagsKey(0), f)
+	}
+	return ctx
+}
+
+// sampledFlagFor returns the sampled flag carried by the given context for the
+// given root ID, if any.
+func sampledFlagFor(ctx context.Context, root lunk.ID) (sampled, ok bool) {
+	f, ok := ctx.Value(traceFlagsKey(0)).(traceFlags)
+	if ok && f.root == root {
+		return f.sampled, true
+	}
+	return false, false
+}
+
+type traceIDKey int
+
+// A fullTraceID is the 128-bit trace ID from which a root ID was taken.
+type fullTraceID struct {
+	root lunk.ID
+	s    string
+}
+
+// withTraceID returns a copy of the given context which carries the 128-bit
+// trace ID of the request's traceparent or B3 headers, if the given root ID was
+// taken from it.
+func withTraceID(ctx context.Context, r *http.Request, root lunk.ID) context.Context {
+	candidates := []string{r.Header.Get(HeaderB3TraceID)}
+	if parts := strings.Split(r.Header.Get(HeaderTraceParent), "-"); len(parts) >= 2 {
+		candidates = append(candidates, parts[1])
+	}
+	if parts := strings.Split(r.Header.Get(HeaderB3), "-"); len(parts) >= 2 {
+		candidates = append(candidates, parts[0])
+	}
+
+	for _, s := range candidates {
+		if id, err := parseTraceID(s); err == nil && id == root && len(s) == 32 {
+			return context.WithValue(ctx, traceIDKey(0), fullTraceID{root: root, s: s})
+		}
+	}
+	return ctx
+}
+
+// fullTraceIDFor returns the 128-bit trace ID carried by the given context for
+// the given root ID, if any.
+func fullTraceIDFor(ctx context.Context, root lunk.ID) (string, bool) {
+	t, ok := ctx.Value(traceIDKey(0)).(fullTraceID)
+	if ok && t.root == root {
+		return t.s, true
+	}
+	return "", false
+}
+
+// traceID returns the 128-bit trace ID carried by the given context for the
+// given root ID, or the root ID padded with zeros if there is none.
+func traceID(ctx context.Context, root lunk.ID) string {
+	if s, ok := fullTraceIDFor(ctx, root); ok {
+		return s
+	}
+	return fmt.Sprintf("%016x%s", 0, root)
+}
@@ -0,0 +1,320 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/lunk"
+)
+
+func TestSetRequestTraceParent(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+
+	SetRequestTraceParent(&r, lunk.EventID{
+		Root: 100,
+		ID:   150,
+	})
+
+	actual := r.Header.Get("traceparent")
+	expected := "00-00000000000000000000000000000064-0000000000000096-01"
+	if actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+
+	SetRequestTraceParent(&r, lunk.EventID{
+		Root:     100,
+		ID:       150,
+		Sampling: lunk.SamplingDrop,
+	})
+
+	actual = r.Header.Get("traceparent")
+	expected = "00-00000000000000000000000000000064-0000000000000096-00"
+	if actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+}
+
+func TestGetRequestTraceParent(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Add("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	id, err := GetRequestTraceParent(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id.Root != 0x8448eb211c80319c || id.ID != 0xb7ad6b7169203331 || id.Parent != 0 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	// the sampled flag is ignored
+	if id.Sampling != lunk.SamplingUndecided {
+		t.Errorf("Was %v, but expected %v", id.Sampling, lunk.SamplingUndecided)
+	}
+}
+
+func TestTraceContextPropagatorTrustSampledFlag(t *testing.T) {
+	p := TraceContextPropagator{TrustSampledFlag: true}
+	for flags, expected := range map[string]lunk.SamplingDecision{
+		"00": lunk.SamplingDrop,
+		"01": lunk.SamplingKeep,
+		"03": lunk.SamplingKeep,
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		r.Header.Add("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-"+flags)
+
+		id, err := p.Extract(&r)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if id.Sampling != expected {
+			t.Errorf("Was %v, but expected %v", id.Sampling, expected)
+		}
+	}
+}
+
+func TestTraceContextPropagatorSampleRate(t *testing.T) {
+	for _, test := range []struct {
+		rate     float64
+		sampling lunk.SamplingDecision
+		expected string
+	}{
+		{0, lunk.SamplingUndecided, "01"},
+		{-1, lunk.SamplingUndecided, "00"},
+		{1, lunk.SamplingUndecided, "01"},
+		{1, lunk.SamplingDrop, "00"},
+		{0, lunk.SamplingKeep, "01"},
+		{0, lunk.SamplingDebug, "01"},
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		TraceContextPropagator{SampleRate: test.rate}.Inject(&r, lunk.EventID{
+			Root:     100,
+			ID:       150,
+			Sampling: test.sampling,
+		})
+
+		expected := "00-00000000000000000000000000000064-0000000000000096-" + test.expected
+		if actual := r.Header.Get("traceparent"); actual != expected {
+			t.Errorf("Was %#v, but expected %#v", actual, expected)
+		}
+	}
+
+	// the same decision is made for every event in a tree
+	p := TraceContextPropagator{SampleRate: 0.5}
+	for i := 0; i < 100; i++ {
+		root := lunk.NewRootEventID()
+		a, b := http.Request{Header: http.Header{}}, http.Request{Header: http.Header{}}
+		p.Inject(&a, root)
+		p.Inject(&b, lunk.NewEventID(root))
+
+		flags := func(r http.Request) string {
+			s := r.Header.Get("traceparent")
+			return s[len(s)-2:]
+		}
+
+		if flags(a) != flags(b) {
+			t.Fatalf("Root %v was not sampled consistently", root.Root)
+		}
+	}
+}
+
+func TestGetRequestTraceParentMissing(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+
+	id, err := GetRequestTraceParent(&r)
+
+	if id != nil {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestGetRequestTraceParentMalformed(t *testing.T) {
+	for _, s := range []string{
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-woo",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-B7AD6B7169203331-01",
+		"00-0af7651916cd43dd8448eb211c8031-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b716920333g-01",
+	} {
+		r := http.Request{
+			Header: http.Header{},
+		}
+		r.Header.Add("traceparent", s)
+
+		id, err := GetRequestTraceParent(&r)
+
+		if id != nil {
+			t.Errorf("Unexpected event ID for %q: %+v", s, id)
+		}
+
+		if err != ErrBadTraceParent {
+			t.Errorf("Unexpected error for %q: %v", s, err)
+		}
+	}
+}
+
+func TestGetRequestTraceParentFutureVersion(t *testing.T) {
+	r := http.Request{
+		Header: http.Header{},
+	}
+	r.Header.Add("traceparent", "01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-woo")
+
+	id, err := GetRequestTraceParent(&r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id.Root != 0x8448eb211c80319c || id.ID != 0xb7ad6b7169203331 {
+		t.Errorf("Unexpected event ID: %+v", id)
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	l := &fakeLogger{}
+	var sent http.Header
+	client := &http.Client{
+		Transport: &Transport{
+			Logger: l,
+			Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				sent = r.Header
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			}),
+		},
+	}
+
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Do(req.WithContext(r.Context())); err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/woo", nil)
+	r.Header.Set("traceparent", "00-000000000000000000000000000000c8-00000000000000fa-01")
+	r.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(l.events) != 2 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	outbound, server := l.events[0].id, l.events[1].id
+	if server.Root != 200 || server.Parent != 250 {
+		t.Errorf("Unexpected server event ID: %+v", server)
+	}
+
+	if outbound.Root != 200 || outbound.Parent != server.ID {
+		t.Errorf("Unexpected client event ID: %+v", outbound)
+	}
+
+	expected := "00-000000000000000000000000000000c8-" + outbound.ID.String() + "-01"
+	if actual := sent.Get("traceparent"); actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+
+	if actual := sent.Get("tracestate"); actual != "congo=t61rcWkgMzE" {
+		t.Errorf("Unexpected tracestate: %#v", actual)
+	}
+}
+
+func TestTraceContextPropagation128BitTraceID(t *testing.T) {
+	l := &fakeLogger{}
+	var sent http.Header
+	client := &http.Client{
+		Transport: &Transport{
+			Logger: l,
+			Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				sent = r.Header
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			}),
+		},
+	}
+
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Do(req.WithContext(r.Context())); err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/woo", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(l.events) != 2 {
+		t.Fatalf("Unexpected events: %+v", l.events)
+	}
+
+	outbound := l.events[0].id
+	if outbound.Root != 0xa3ce929d0e0e4736 {
+		t.Errorf("Unexpected client event ID: %+v", outbound)
+	}
+
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + outbound.ID.String() + "-01"
+	if actual := sent.Get("traceparent"); actual != expected {
+		t.Errorf("Was %#v, but expected %#v", actual, expected)
+	}
+}
+
+func TestTraceContextPropagationSampledFlag(t *testing.T) {
+	for _, flags := range []string{"00", "01"} {
+		var sent http.Header
+		client := &http.Client{
+			Transport: &Transport{
+				Logger: &fakeLogger{},
+				Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					sent = r.Header
+					return &http.Response{StatusCode: http.StatusOK}, nil
+				}),
+				// the incoming flag takes precedence over the sample rate
+				Propagator: TraceContextPropagator{SampleRate: 1},
+			},
+		}
+
+		h := Handler(&fakeLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := http.NewRequest("GET", "http://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.Do(req.WithContext(r.Context())); err != nil {
+				t.Fatal(err)
+			}
+		}))
+
+		r := httptest.NewRequest("GET", "/woo", nil)
+		r.Header.Set("traceparent", "00-000000000000000000000000000000c8-00000000000000fa-"+flags)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if actual := sent.Get("traceparent"); actual[len(actual)-2:] != flags {
+			t.Errorf("Was %#v, but expected flags %#v", actual, flags)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/codahale/lunk"
+)
+
+// Transport is an http.RoundTripper which propagates event IDs to the servers
+// it sends requests to, and logs an HTTPClientEvent for each request it sends.
+//
+// If the outgoing request's context carries an EventID (see lunk.NewContext),
+// the logged event is a child of that event. Failing that, if the Transport's
+// Propagator extracts a valid EventID from the request, the logged event is a
+// child of that event. Otherwise, the logged event is a new root event. The
+// Propagator then injects the ID of the logged event into the request, which
+// allows the receiving server to properly parent its own events.
+type Transport struct {
+	// Logger is the EventLogger to which events are logged.
+	Logger lunk.EventLogger
+
+	// Base is the underlying http.RoundTripper used to send requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Propagator is used to extract EventIDs from and inject EventIDs into
+	// requests. If nil, DefaultPropagator is used.
+	Propagator Propagator
+}
+
+// RoundTrip sends the request and logs an HTTPClientEvent.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	var id lunk.EventID
+	if parent, ok := lunk.FromContext(ctx); ok {
+		id = lunk.NewEventID(parent)
+	} else if parent, err := t.propagator().Extract(r); err == nil && parent != nil {
+		id = childEventID(*parent)
+		ctx = withTraceFlags(withTraceID(ctx, r, id.Root), r, id.Root)
+	} else {
+		id = lunk.NewRootEventID()
+	}
+	ctx = lunk.NewContext(ctx, id)
+
+	// RoundTrippers must not modify the request, so modify a copy instead
+	r2 := r.Clone(ctx)
+	t.propagator().Inject(r2, id)
+	setTraceState(ctx, r2)
+
+	e := &HTTPClientEvent{
+		Method: r.Method,
+		URL:    r.URL.Redacted(),
+	}
+
+	start := time.Now()
+	resp, err := t.base().RoundTrip(r2)
+	e.Elapsed = time.Now().Sub(start)
+
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		e.Status = resp.StatusCode
+	}
+
+	t.Logger.Log(id, e)
+
+	return resp, err
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) propagator() Propagator {
+	if t.Propagator == nil {
+		return DefaultPropagator
+	}
+	return t.Propagator
+}
+
+// HTTPClientEvent records an HTTP request sent by a client. Passwords in the
+// request's URL are redacted.
+type HTTPClientEvent struct {
+	Method  string        `lunk:"method"`
+	URL     string        `lunk:"url"`
+	Status  int           `lunk:"status"`
+	Elapsed time.Duration `lunk:"elapsed"`
+	Error   string        `lunk:"error"`
+}
+
+// Schema returns the constant "httpclient".
+func (HTTPClientEvent) Schema() string {
+	return "httpclient"
+}